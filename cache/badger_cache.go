@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
@@ -9,10 +11,19 @@ import (
 type BadgerCache struct {
 	Conn   *badger.DB
 	Prefix string
+	counters
+}
+
+// Ping checks that the badger database is open
+func (b *BadgerCache) Ping() error {
+	if b.Conn == nil || b.Conn.IsClosed() {
+		return errors.New("badger database is closed")
+	}
+	return nil
 }
 
 func (b *BadgerCache) Has(str string) (bool, error) {
-	_, err := b.Get(str)
+	_, err := b.get(str)
 	if err != nil {
 		return false, nil
 	}
@@ -20,6 +31,18 @@ func (b *BadgerCache) Has(str string) (bool, error) {
 }
 
 func (b *BadgerCache) Get(str string) (interface{}, error) {
+	item, err := b.get(str)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			b.miss()
+		}
+		return nil, err
+	}
+	b.hit()
+	return item, nil
+}
+
+func (b *BadgerCache) get(str string) (interface{}, error) {
 	var fromCache []byte
 
 	err := b.Conn.View(func(txn *badger.Txn) error {
@@ -52,6 +75,12 @@ func (b *BadgerCache) Get(str string) (interface{}, error) {
 }
 
 func (b *BadgerCache) Set(str string, value interface{}, expires ...int) error {
+	return b.SetTagged(str, value, nil, expires...)
+}
+
+// SetTagged stores a value like Set, and writes an index key for each tag, which expires with the
+// entry. The tags the entry was set with before are removed.
+func (b *BadgerCache) SetTagged(str string, value interface{}, tags []string, expires ...int) error {
 	entry := Entry{}
 
 	entry[str] = value
@@ -60,25 +89,148 @@ func (b *BadgerCache) Set(str string, value interface{}, expires ...int) error {
 		return err
 	}
 
-	if len(expires) > 0 {
-		err = b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded).WithTTL(time.Second * time.Duration(expires[0]))
-			err = txn.SetEntry(e)
+	return b.Conn.Update(func(txn *badger.Txn) error {
+		if err := untag(txn, str); err != nil {
 			return err
-		})
-	} else {
-		err = b.Conn.Update(func(txn *badger.Txn) error {
-			e := badger.NewEntry([]byte(str), encoded)
-			err = txn.SetEntry(e)
+		}
+
+		entries := []*badger.Entry{badger.NewEntry([]byte(str), encoded)}
+		for _, tag := range tags {
+			entries = append(entries, badger.NewEntry(append(tagPrefix(tag), str...), nil))
+		}
+		if len(tags) > 0 {
+			entries = append(entries, badger.NewEntry(keyTagsKey(str), []byte(strings.Join(tags, "\x00"))))
+		}
+
+		for _, e := range entries {
+			if len(expires) > 0 {
+				e = e.WithTTL(time.Second * time.Duration(expires[0]))
+			}
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flushBatch is the most entries FlushTags deletes in one transaction, to begin with
+const flushBatch = 1000
+
+// FlushTags removes every entry that was set with any of tags. Each entry's tags are read and the
+// entry deleted in the same transaction, so if the entry is set again meanwhile the transaction
+// conflicts and is retried, rather than deleting the new value or orphaning its tags.
+func (b *BadgerCache) FlushTags(tags ...string) error {
+	for _, tag := range tags {
+		limit := flushBatch
+		for {
+			var done bool
+			err := b.Conn.Update(func(txn *badger.Txn) error {
+				var err error
+				done, err = flushTag(txn, tag, limit)
+				return err
+			})
+			switch {
+			case errors.Is(err, badger.ErrConflict):
+				continue
+			case errors.Is(err, badger.ErrTxnTooBig) && limit > 1:
+				limit /= 2
+				continue
+			case err != nil:
+				return err
+			}
+			if done {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// flushTag deletes up to limit of the entries indexed under tag, along with their tags, and
+// reports whether none are left
+func flushTag(txn *badger.Txn, tag string, limit int) (bool, error) {
+	prefix := tagPrefix(tag)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var indexes [][]byte
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if len(indexes) == limit {
+			break
+		}
+		indexes = append(indexes, it.Item().KeyCopy(nil))
+	}
+	done := !it.ValidForPrefix(prefix)
+
+	for _, index := range indexes {
+		str := string(index[len(prefix):])
+		if err := untag(txn, str); err != nil {
+			return false, err
+		}
+		if err := txn.Delete(index); err != nil {
+			return false, err
+		}
+		if err := txn.Delete([]byte(str)); err != nil {
+			return false, err
+		}
+	}
+	return done, nil
+}
+
+// untag deletes the index keys of the tags str was set with, and the list of them
+func untag(txn *badger.Txn, str string) error {
+	tags, err := entryTags(txn, str)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := txn.Delete(append(tagPrefix(tag), str...)); err != nil {
 			return err
-		})
+		}
 	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return txn.Delete(keyTagsKey(str))
+}
 
-	return nil
+// entryTags returns the tags str was last set with
+func entryTags(txn *badger.Txn, str string) ([]string, error) {
+	item, err := txn.Get(keyTagsKey(str))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	err = item.Value(func(val []byte) error {
+		tags = strings.Split(string(val), "\x00")
+		return nil
+	})
+	return tags, err
+}
+
+// tagPrefix starts the index keys for tag. The leading zero byte keeps them apart from cache keys,
+// and the trailing one separates the tag from the key it indexes.
+func tagPrefix(tag string) []byte {
+	return []byte("\x00tag:" + tag + "\x00")
+}
+
+// keyTagsKey holds the tags the entry str was set with, separated by zero bytes
+func keyTagsKey(str string) []byte {
+	return []byte("\x00tags:" + str)
 }
 
 func (b *BadgerCache) Forget(str string) error {
 	err := b.Conn.Update(func(txn *badger.Txn) error {
+		if err := untag(txn, str); err != nil {
+			return err
+		}
 		err := txn.Delete([]byte(str))
 		return err
 	})
@@ -3,7 +3,9 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -17,8 +19,46 @@ type Cache interface {
 	Empty() error
 }
 
+// TaggedCache is a Cache that can group entries under tags. The caches in this package implement
+// it; check for it with a type assertion, since other Cache implementations may not.
+type TaggedCache interface {
+	Cache
+	// SetTagged is Set for an entry that belongs to tags, such as "user:1", so that it can be
+	// removed along with everything else for that user by FlushTags, whatever its key
+	SetTagged(string, interface{}, []string, ...int) error
+	FlushTags(...string) error
+}
+
 type Entry map[string]interface{}
 
+// Stats counts the Get calls that found a value (hits) and that did not (misses)
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// counters is embedded in each cache implementation to record hits and misses
+type counters struct {
+	hits   uint64
+	misses uint64
+}
+
+func (c *counters) hit() {
+	atomic.AddUint64(&c.hits, 1)
+}
+
+func (c *counters) miss() {
+	atomic.AddUint64(&c.misses, 1)
+}
+
+// Stats returns the hit and miss counts since the cache was created
+func (c *counters) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
 func encode(item Entry) ([]byte, error) {
 	b := bytes.Buffer{}
 	e := gob.NewEncoder(&b)
@@ -66,9 +106,22 @@ func (c *RedisCache) getKeys(pattern string) ([]string, error) {
 	return keys, nil
 }
 
+// RedisCache stores entries in redis under Prefix. It needs a single redis server, optionally with
+// replicas: Redis Cluster is not supported, since the scripts behind SetTagged and FlushTags reach
+// keys they only find while running, and Empty and EmptyByMatch scan a single server.
 type RedisCache struct {
 	Conn   *redis.Pool
 	Prefix string
+	counters
+}
+
+// Ping checks that redis is reachable
+func (c *RedisCache) Ping() error {
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	return err
 }
 
 func (c *RedisCache) Has(str string) (bool, error) {
@@ -91,8 +144,12 @@ func (c *RedisCache) Get(str string) (interface{}, error) {
 
 	cacheEntry, err := redis.Bytes(conn.Do("GET", key))
 	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			c.miss()
+		}
 		return nil, err
 	}
+	c.hit()
 
 	decoded, err := decode(string(cacheEntry))
 	if err != nil {
@@ -105,6 +162,48 @@ func (c *RedisCache) Get(str string) (interface{}, error) {
 }
 
 func (c *RedisCache) Set(str string, value interface{}, expires ...int) error {
+	return c.SetTagged(str, value, nil, expires...)
+}
+
+// setTaggedScript stores an entry and replaces the tags it was last set with. Each tag's set lasts
+// at least as long as the entries in it, and a separate set records the entry's own tags, so that
+// setting the entry again removes it from tags it no longer has.
+//
+// KEYS: the entry, the set of its tags. ARGV: the encoded entry, its ttl in seconds or "" if it
+// does not expire, the prefix of the tag sets, then the tags. The tag sets are named from ARGV,
+// as the old ones are only known once the script reads them, which is why Redis Cluster is not
+// supported.
+var setTaggedScript = redis.NewScript(2, `
+for _, tag in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	redis.call('SREM', ARGV[3] .. tag, KEYS[1])
+end
+redis.call('DEL', KEYS[2])
+
+local ttl = tonumber(ARGV[2])
+if ttl then
+	redis.call('SET', KEYS[1], ARGV[1], 'EX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+
+for i = 4, #ARGV do
+	local tagKey = ARGV[3] .. ARGV[i]
+	local current = redis.call('TTL', tagKey)
+	redis.call('SADD', tagKey, KEYS[1])
+	redis.call('SADD', KEYS[2], ARGV[i])
+	if not ttl then
+		redis.call('PERSIST', tagKey)
+	elseif current == -2 or (current >= 0 and current < ttl) then
+		redis.call('EXPIRE', tagKey, ttl)
+	end
+end
+if ttl and #ARGV > 3 then
+	redis.call('EXPIRE', KEYS[2], ttl)
+end
+`)
+
+// SetTagged stores a value like Set, and adds its key to a redis set for each tag, atomically
+func (c *RedisCache) SetTagged(str string, value interface{}, tags []string, expires ...int) error {
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
@@ -116,27 +215,71 @@ func (c *RedisCache) Set(str string, value interface{}, expires ...int) error {
 		return err
 	}
 
+	ttl := ""
 	if len(expires) > 0 {
-		_, err := conn.Do("SETEX", key, expires[0], string(encoded))
-		if err != nil {
-			return err
-		}
-	} else {
-		_, err := conn.Do("SET", key, string(encoded))
+		ttl = fmt.Sprint(expires[0])
+	}
+
+	args := []interface{}{key, c.keyTagsKey(str), string(encoded), ttl, c.tagKey("")}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+
+	_, err = setTaggedScript.Do(conn, args...)
+	return err
+}
+
+// flushTagScript deletes every entry in a tag's set, and the set itself, atomically, so an entry
+// tagged while the tag is being flushed is not left behind. Entries that have since been set
+// without the tag are kept.
+//
+// KEYS: the tag's set. ARGV: the tag, the prefix of the sets of an entry's tags, and the length
+// of the cache prefix to replace with it. The entries are read from the tag's set rather than
+// passed in KEYS, which is why Redis Cluster is not supported.
+var flushTagScript = redis.NewScript(1, `
+local flushed = 0
+for _, key in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	local keyTags = ARGV[2] .. string.sub(key, tonumber(ARGV[3]) + 1)
+	if redis.call('SISMEMBER', keyTags, ARGV[1]) == 1 then
+		redis.call('DEL', key, keyTags)
+		flushed = flushed + 1
+	end
+end
+redis.call('DEL', KEYS[1])
+return flushed
+`)
+
+// FlushTags removes every entry that was set with any of tags
+func (c *RedisCache) FlushTags(tags ...string) error {
+	conn := c.Conn.Get()
+	defer conn.Close()
+
+	for _, tag := range tags {
+		_, err := flushTagScript.Do(conn, c.tagKey(tag), tag, c.keyTagsKey(""), len(c.Prefix)+1)
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
+// tagKey is the redis set that holds the keys set with tag. It shares the cache prefix, so Empty
+// removes the tags along with the entries.
+func (c *RedisCache) tagKey(tag string) string {
+	return fmt.Sprintf("%s:__tag:%s", c.Prefix, tag)
+}
+
+// keyTagsKey is the redis set that holds the tags the entry str was last set with
+func (c *RedisCache) keyTagsKey(str string) string {
+	return fmt.Sprintf("%s:__tags:%s", c.Prefix, str)
+}
+
 func (c *RedisCache) Forget(str string) error {
 	key := fmt.Sprintf("%s:%s", c.Prefix, str)
 	conn := c.Conn.Get()
 	defer conn.Close()
 
-	_, err := conn.Do("DEL", key)
+	_, err := conn.Do("DEL", key, c.keyTagsKey(str))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by MemoryCache.Get for a key that is missing or has expired
+var ErrNotFound = errors.New("cache: key not found")
+
+// MemoryCache keeps entries in the application's memory. Once it holds maxEntries entries or
+// maxBytes bytes, the least recently used entries are evicted to make room. Values are gob
+// encoded, as they are for redis and badger, so Get returns a copy of what was stored.
+type MemoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List                     // most recently used at the front
+	tags       map[string]map[string]struct{} // the keys set with each tag
+	size       int64
+	maxEntries int
+	maxBytes   int64
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	counters
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	tags    []string
+	expires time.Time // zero if the entry does not expire
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// NewMemoryCache creates an in-memory cache. A maxEntries or maxBytes of 0 is unlimited. Expired
+// entries are removed every cleanupInterval by a background goroutine, which runs until Close.
+func NewMemoryCache(maxEntries int, maxBytes int64, cleanupInterval time.Duration) *MemoryCache {
+	c := &MemoryCache{
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		tags:       make(map[string]map[string]struct{}),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	go c.janitor(cleanupInterval)
+
+	return c
+}
+
+func (c *MemoryCache) Has(str string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.lookup(str, time.Now())
+	return ok, nil
+}
+
+func (c *MemoryCache) Get(str string) (interface{}, error) {
+	c.mu.Lock()
+	e, ok := c.lookup(str, time.Now())
+	if !ok {
+		c.mu.Unlock()
+		c.miss()
+		return nil, ErrNotFound
+	}
+	encoded := e.value
+	c.mu.Unlock()
+	c.hit()
+
+	decoded, err := decode(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded[str], nil
+}
+
+func (c *MemoryCache) Set(str string, value interface{}, expires ...int) error {
+	return c.SetTagged(str, value, nil, expires...)
+}
+
+// SetTagged stores a value like Set, and records it under each tag for FlushTags
+func (c *MemoryCache) SetTagged(str string, value interface{}, tags []string, expires ...int) error {
+	entry := Entry{}
+	entry[str] = value
+	encoded, err := encode(entry)
+	if err != nil {
+		return err
+	}
+
+	e := &memoryEntry{key: str, value: encoded, tags: append([]string(nil), tags...)}
+	if len(expires) > 0 {
+		e.expires = time.Now().Add(time.Second * time.Duration(expires[0]))
+	}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return fmt.Errorf("cache: %s is %d bytes, more than the cache holds", str, e.size())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[str]; ok {
+		c.remove(el)
+	}
+	c.items[str] = c.lru.PushFront(e)
+	c.size += e.size()
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][str] = struct{}{}
+	}
+
+	for c.full() {
+		c.remove(c.lru.Back())
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) Forget(str string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[str]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+// FlushTags removes every entry that was set with any of tags
+func (c *MemoryCache) FlushTags(tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if el, ok := c.items[key]; ok {
+				c.remove(el)
+			}
+		}
+		delete(c.tags, tag)
+	}
+	return nil
+}
+
+func (c *MemoryCache) EmptyByMatch(str string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, str) {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCache) Empty() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.tags = make(map[string]map[string]struct{})
+	c.lru.Init()
+	c.size = 0
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones not yet removed
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Size returns the number of bytes held by keys and encoded values
+func (c *MemoryCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// Close stops the goroutine that removes expired entries
+func (c *MemoryCache) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+	})
+	return nil
+}
+
+// lookup returns the live entry for key and marks it as recently used. An expired entry is
+// removed. The caller must hold mu.
+func (c *MemoryCache) lookup(key string, now time.Time) (*memoryEntry, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*memoryEntry)
+	if e.expired(now) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+// full reports whether the cache is over either of its limits. The caller must hold mu.
+func (c *MemoryCache) full() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+	return (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes)
+}
+
+// remove deletes an entry. The caller must hold mu.
+func (c *MemoryCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*memoryEntry)
+	delete(c.items, e.key)
+	c.size -= e.size()
+
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+func (c *MemoryCache) janitor(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.removeExpired(now)
+		}
+	}
+}
+
+// removeExpired deletes every entry that has expired by now
+func (c *MemoryCache) removeExpired(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*memoryEntry).expired(now) {
+			c.remove(el)
+		}
+		el = prev
+	}
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_SetGet(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	err := c.Set("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.Get("foo")
+	if err != nil || v != "bar" {
+		t.Errorf("expected bar, got %v: %v", v, err)
+	}
+
+	if ok, _ := c.Has("foo"); !ok {
+		t.Error("expected foo to be in the cache")
+	}
+
+	_, err = c.Get("missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound for a missing key, got", err)
+	}
+
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected one hit and one miss, got %+v", stats)
+	}
+
+	_ = c.Forget("foo")
+	if ok, _ := c.Has("foo"); ok {
+		t.Error("expected foo to be forgotten")
+	}
+}
+
+func TestMemoryCache_Expires(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	_ = c.Set("short", 1, 1)
+	_ = c.Set("forever", 2)
+
+	if ok, _ := c.Has("short"); !ok {
+		t.Fatal("expected short to be in the cache before it expires")
+	}
+
+	c.removeExpired(time.Now().Add(2 * time.Second))
+
+	if ok, _ := c.Has("short"); ok {
+		t.Error("expected short to be removed once expired")
+	}
+	if ok, _ := c.Has("forever"); !ok {
+		t.Error("expected an entry without a ttl to be kept")
+	}
+	if c.Len() != 1 {
+		t.Error("expected one entry left, got", c.Len())
+	}
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(2, 0, time.Minute)
+	defer c.Close()
+
+	_ = c.Set("a", 1)
+	_ = c.Set("b", 2)
+	_, _ = c.Get("a")
+	_ = c.Set("c", 3)
+
+	if ok, _ := c.Has("b"); ok {
+		t.Error("expected b, the least recently used entry, to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if ok, _ := c.Has(key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+}
+
+func TestMemoryCache_MaxBytes(t *testing.T) {
+	c := NewMemoryCache(0, 100, time.Minute)
+	defer c.Close()
+
+	// each of these entries is 41 bytes, so only two fit
+	for _, key := range []string{"a", "b", "c"} {
+		_ = c.Set(key, "x")
+	}
+
+	if c.Len() != 2 || c.Size() > 100 {
+		t.Errorf("expected two entries within 100 bytes, got %d entries of %d bytes", c.Len(), c.Size())
+	}
+	if ok, _ := c.Has("a"); ok {
+		t.Error("expected the oldest entry to be evicted to make room")
+	}
+
+	if err := c.Set("big", make([]byte, 500)); err == nil {
+		t.Error("expected an error for an entry larger than the cache")
+	}
+}
+
+func TestMemoryCache_EmptyByMatch(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	_ = c.Set("user:1", 1)
+	_ = c.Set("user:2", 2)
+	_ = c.Set("post:1", 3)
+
+	_ = c.EmptyByMatch("user:")
+	if c.Len() != 1 {
+		t.Error("expected only post:1 to be left, got", c.Len())
+	}
+
+	_ = c.Empty()
+	if c.Len() != 0 || c.Size() != 0 {
+		t.Error("expected the cache to be empty")
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// rememberGroup makes concurrent misses on the same key within this process share one call to fn
+var rememberGroup singleflight.Group
+
+// RememberOption changes how Remember caches a value
+type RememberOption func(*rememberOptions)
+
+type rememberOptions struct {
+	stale time.Duration
+	tags  []string
+}
+
+// WithStale keeps serving a value for up to d after its ttl has passed, while a fresh value is
+// computed in the background. Callers only wait for fn when nothing is cached at all.
+func WithStale(d time.Duration) RememberOption {
+	return func(o *rememberOptions) {
+		o.stale = d
+	}
+}
+
+// WithTags stores the value under tags, so FlushTags removes it. It has no effect on a cache that
+// is not a TaggedCache.
+func WithTags(tags ...string) RememberOption {
+	return func(o *rememberOptions) {
+		o.tags = tags
+	}
+}
+
+// remembered is what Remember stores under a key. It is gob encoded as a concrete type, so values
+// of any type can be cached without being registered with gob.
+type remembered[T any] struct {
+	Value      T
+	FreshUntil time.Time
+}
+
+// Remember returns the value cached under key, or calls fn, caches its result for ttl and returns
+// it. A ttl of 0 caches the value until it is forgotten. Concurrent misses on the same key share a
+// single call to fn. Errors from fn are returned and not cached; errors reading or writing the
+// cache are treated as a miss, so a cache outage slows requests down rather than failing them.
+// The value is stored gob encoded, so T must be a type gob can encode.
+func Remember[T any](c Cache, key string, ttl time.Duration, fn func() (T, error), opts ...RememberOption) (T, error) {
+	var o rememberOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	compute := func() (interface{}, error) {
+		value, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		if err := store(c, key, ttl, o, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	// the cache and the type are part of the flight key, so callers using the same key in another
+	// cache, or for another type, never receive each other's values
+	var zero T
+	flight := fmt.Sprintf("%p\x00%s\x00%T", c, key, zero)
+
+	if entry, ok := lookup[T](c, key); ok {
+		if o.stale > 0 && !entry.FreshUntil.IsZero() && time.Now().After(entry.FreshUntil) {
+			go func() {
+				// nobody is waiting for a background refresh, so a panic in fn is dropped rather
+				// than taking the process down
+				defer func() { _ = recover() }()
+				_, _, _ = rememberGroup.Do(flight, compute)
+			}()
+		}
+		return entry.Value, nil
+	}
+
+	value, err, _ := rememberGroup.Do(flight, compute)
+	if err != nil {
+		return zero, err
+	}
+	// fn may return a nil interface, which does not assert to an interface T
+	v, _ := value.(T)
+	return v, nil
+}
+
+func lookup[T any](c Cache, key string) (remembered[T], bool) {
+	var entry remembered[T]
+
+	v, err := c.Get(key)
+	if err != nil {
+		return entry, false
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return entry, false
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// store caches value under key. Only a value that cannot be encoded is an error; a failed write
+// just means the value is computed again next time.
+func store[T any](c Cache, key string, ttl time.Duration, o rememberOptions, value T) error {
+	entry := remembered[T]{Value: value}
+	if ttl > 0 {
+		entry.FreshUntil = time.Now().Add(ttl)
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(entry); err != nil {
+		return fmt.Errorf("cache: cannot remember a %T: %w", value, err)
+	}
+
+	// the cache keeps the entry through the stale window; FreshUntil marks where its ttl ends
+	var expires []int
+	if ttl > 0 {
+		expires = append(expires, int((ttl+o.stale+time.Second-1)/time.Second))
+	}
+
+	if tc, ok := c.(TaggedCache); ok {
+		_ = tc.SetTagged(key, b.Bytes(), o.tags, expires...)
+		return nil
+	}
+	_ = c.Set(key, b.Bytes(), expires...)
+	return nil
+}
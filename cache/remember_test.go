@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type rememberedUser struct {
+	ID    int
+	Email string
+}
+
+func TestRemember(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	var calls int
+	load := func() (rememberedUser, error) {
+		calls++
+		return rememberedUser{ID: 1, Email: "a@example.com"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		u, err := Remember(c, "user:1", time.Minute, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Email != "a@example.com" {
+			t.Errorf("unexpected user %+v", u)
+		}
+	}
+	if calls != 1 {
+		t.Error("expected the value to be computed once, got", calls)
+	}
+}
+
+func TestRemember_ErrorsAreNotCached(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	boom := errors.New("boom")
+	_, err := Remember(c, "key", time.Minute, func() (int, error) { return 0, boom })
+	if !errors.Is(err, boom) {
+		t.Fatal("expected the error from fn, got", err)
+	}
+
+	v, err := Remember(c, "key", time.Minute, func() (int, error) { return 42, nil })
+	if err != nil || v != 42 {
+		t.Errorf("expected the value to be computed after an error, got %v: %v", v, err)
+	}
+}
+
+func TestRemember_ConcurrentMisses(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := Remember(c, "slow", time.Minute, load)
+			if err != nil || v != "value" {
+				t.Errorf("unexpected result %q: %v", v, err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Error("expected concurrent misses to share one computation, got", calls.Load())
+	}
+}
+
+func TestRemember_ConcurrentMissesInTwoCaches(t *testing.T) {
+	first := NewMemoryCache(0, 0, time.Minute)
+	defer first.Close()
+	second := NewMemoryCache(0, 0, time.Minute)
+	defer second.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		v, err := Remember(first, "shared", time.Minute, func() (string, error) {
+			close(started)
+			<-release
+			return "first", nil
+		})
+		if err != nil || v != "first" {
+			t.Errorf("first cache: unexpected result %q: %v", v, err)
+		}
+	}()
+
+	// the second cache misses while the first cache's computation is in flight
+	<-started
+	go func() {
+		defer wg.Done()
+		v, err := Remember(second, "shared", time.Minute, func() (string, error) {
+			return "second", nil
+		})
+		if err != nil || v != "second" {
+			t.Errorf("second cache: unexpected result %q: %v", v, err)
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if v, _ := Remember(second, "shared", time.Minute, func() (string, error) { return "", errors.New("not cached") }); v != "second" {
+		t.Errorf("expected the second cache to hold its own value, got %q", v)
+	}
+}
+
+func TestRemember_Stale(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	var version atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	load := func() (int32, error) {
+		v := version.Add(1)
+		if v > 1 {
+			refreshed <- struct{}{}
+		}
+		return v, nil
+	}
+
+	v, _ := Remember(c, "stale", 10*time.Millisecond, load, WithStale(time.Minute))
+	if v != 1 {
+		t.Fatal("expected the first value, got", v)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	v, _ = Remember(c, "stale", 10*time.Millisecond, load, WithStale(time.Minute))
+	if v != 1 {
+		t.Error("expected the stale value to be served while refreshing, got", v)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the value to be refreshed in the background")
+	}
+
+	// the refresh stores its value after fn returns
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		v, _ = Remember(c, "stale", time.Minute, load, WithStale(time.Minute))
+		if v == 2 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("expected the refreshed value to be served, got", v)
+}
+
+func TestRemember_NilInterface(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	v, err := Remember(c, "nothing", time.Minute, func() (fmt.Stringer, error) {
+		return nil, nil
+	})
+	if err != nil || v != nil {
+		t.Errorf("expected a nil value and no error, got %v, %v", v, err)
+	}
+}
+
+func TestRemember_StaleRefreshPanics(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	_, _ = Remember(c, "panics", 10*time.Millisecond, func() (int, error) { return 1, nil }, WithStale(time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	called := make(chan struct{})
+	v, err := Remember(c, "panics", 10*time.Millisecond, func() (int, error) {
+		close(called)
+		panic("refresh failed")
+	}, WithStale(time.Minute))
+	if err != nil || v != 1 {
+		t.Fatalf("expected the stale value, got %v, %v", v, err)
+	}
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("expected the value to be refreshed in the background")
+	}
+	// the recovered panic leaves the stale value in place
+	time.Sleep(10 * time.Millisecond)
+	if v, _ := Remember(c, "panics", time.Minute, func() (int, error) { return 2, nil }, WithStale(time.Minute)); v != 1 {
+		t.Error("expected the stale value to be kept after a failed refresh, got", v)
+	}
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gomodule/redigo/redis"
+)
+
+func taggedCaches(t *testing.T) map[string]TaggedCache {
+	t.Helper()
+
+	mem := NewMemoryCache(0, 0, time.Minute)
+	t.Cleanup(func() { _ = mem.Close() })
+
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", server.Addr()) }}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return map[string]TaggedCache{
+		"memory": mem,
+		"redis":  &RedisCache{Conn: pool, Prefix: "test"},
+		"badger": &BadgerCache{Conn: db},
+	}
+}
+
+func TestCache_FlushTags(t *testing.T) {
+	for name, c := range taggedCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			must := func(err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			must(c.SetTagged("profile:1", "alice", []string{"user:1"}))
+			must(c.SetTagged("orders:1", 3, []string{"user:1", "orders"}, 60))
+			must(c.SetTagged("orders:2", 5, []string{"user:2", "orders"}))
+			must(c.Set("settings", "dark"))
+
+			must(c.FlushTags("user:1"))
+
+			for key, want := range map[string]bool{"profile:1": false, "orders:1": false, "orders:2": true, "settings": true} {
+				if ok, _ := c.Has(key); ok != want {
+					t.Errorf("expected Has(%s) to be %v after flushing user:1", key, want)
+				}
+			}
+
+			must(c.FlushTags("orders", "missing"))
+			if ok, _ := c.Has("orders:2"); ok {
+				t.Error("expected orders:2 to be flushed with the orders tag")
+			}
+			if ok, _ := c.Has("settings"); !ok {
+				t.Error("expected an untagged entry to be kept")
+			}
+		})
+	}
+}
+
+func TestCache_SetReplacesTags(t *testing.T) {
+	for name, c := range taggedCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			must := func(err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			must(c.SetTagged("profile:1", "alice", []string{"user:1"}))
+			must(c.Set("profile:1", "bob"))
+			must(c.SetTagged("orders:1", 3, []string{"user:1"}, 60))
+			must(c.SetTagged("orders:1", 4, []string{"orders"}, 60))
+
+			must(c.FlushTags("user:1"))
+			for _, key := range []string{"profile:1", "orders:1"} {
+				if ok, _ := c.Has(key); !ok {
+					t.Errorf("expected %s to be kept, since it was set again without the user:1 tag", key)
+				}
+			}
+
+			must(c.FlushTags("orders"))
+			if ok, _ := c.Has("orders:1"); ok {
+				t.Error("expected orders:1 to be flushed with the tag it was set with last")
+			}
+		})
+	}
+}
+
+func TestRedisCache_TagSetsExpire(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", server.Addr()) }}
+	defer pool.Close()
+	c := &RedisCache{Conn: pool, Prefix: "test"}
+
+	if err := c.SetTagged("orders:1", 3, []string{"orders"}, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetTagged("orders:2", 4, []string{"orders"}, 30); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("test:__tag:orders"); ttl != 60*time.Second {
+		t.Errorf("expected the tag set to last as long as its longest entry, got %v", ttl)
+	}
+
+	if err := c.SetTagged("orders:3", 5, []string{"orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("test:__tag:orders"); ttl != 0 {
+		t.Errorf("expected the tag set to stop expiring once it holds an entry that does not, got %v", ttl)
+	}
+
+	server.FastForward(61 * time.Second)
+	if server.Exists("test:__tags:orders:1") {
+		t.Error("expected an entry's tags to expire with it")
+	}
+}
+
+func TestBadgerCache_FlushTagsKeepsEntrySetMeanwhile(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := &BadgerCache{Conn: db}
+
+	if err := c.SetTagged("profile:1", "alice", []string{"user:1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// flush in a transaction of our own, and set the entry again before it commits
+	txn := db.NewTransaction(true)
+	defer txn.Discard()
+	if _, err := flushTag(txn, "user:1", flushBatch); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetTagged("profile:1", "bob", []string{"user:2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(); !errors.Is(err, badger.ErrConflict) {
+		t.Fatalf("expected the flush to conflict with the entry set meanwhile, got %v", err)
+	}
+
+	if err := c.FlushTags("user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get("profile:1"); err != nil || v != "bob" {
+		t.Fatalf("expected the entry set meanwhile to survive the flush, got %v, %v", v, err)
+	}
+
+	if err := c.FlushTags("user:2"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := c.Has("profile:1"); ok {
+		t.Error("expected the entry to be flushed with the tag it was set again with")
+	}
+}
+
+func TestRemember_WithTags(t *testing.T) {
+	c := NewMemoryCache(0, 0, time.Minute)
+	defer c.Close()
+
+	calls := 0
+	load := func() (string, error) {
+		calls++
+		return "report", nil
+	}
+
+	_, _ = Remember(c, "report:1", time.Minute, load, WithTags("reports"))
+	_ = c.FlushTags("reports")
+	_, _ = Remember(c, "report:1", time.Minute, load, WithTags("reports"))
+
+	if calls != 2 {
+		t.Error("expected flushing the tag to make Remember compute the value again, got", calls)
+	}
+}
+
+// untaggedCache is a Cache without tag support, as a third party implementation might be
+type untaggedCache struct {
+	Cache
+}
+
+func TestRemember_WithTagsOnUntaggedCache(t *testing.T) {
+	mem := NewMemoryCache(0, 0, time.Minute)
+	defer mem.Close()
+	c := &untaggedCache{Cache: mem}
+
+	if _, ok := Cache(c).(TaggedCache); ok {
+		t.Fatal("expected the wrapper not to be a TaggedCache")
+	}
+
+	calls := 0
+	load := func() (string, error) {
+		calls++
+		return "report", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := Remember[string](c, "report:1", time.Minute, load, WithTags("reports"))
+		if err != nil || v != "report" {
+			t.Fatalf("unexpected result %q: %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Error("expected the value to be cached without its tags, got", calls)
+	}
+}
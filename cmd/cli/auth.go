@@ -9,23 +9,34 @@ import (
 
 func doAuth() error {
 	// migrations
-	dbType := nav.DB.DatabaseType
+	dbType := dbDialect()
 	fileName := fmt.Sprintf("%d_create_auth_tables", time.Now().UnixMicro())
-	upFile := nav.RootPath + "/migrations/" + fileName + ".up.sql"
-	downFile := nav.RootPath + "/migrations/" + fileName + ".down.sql"
+	upFile := nav.MigrationsPath() + "/" + fileName + ".up.sql"
+	downFile := nav.MigrationsPath() + "/" + fileName + ".down.sql"
 
 	err := copyFileFromTemplate("templates/migrations/auth_tables."+dbType+".sql", upFile)
 	if err != nil {
 		exitGracefully(err)
 	}
 
-	err = copyDataToFile([]byte("drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"), downFile)
+	down := "drop table if exists users cascade; drop table if exists tokens cascade; drop table if exists remember_tokens;"
+	if dbType == "sqlite" {
+		// sqlite has no cascade on drop, so dependent tables go first
+		down = "drop table if exists tokens; drop table if exists remember_tokens; drop table if exists users;"
+	}
+
+	err = copyDataToFile([]byte(down), downFile)
 	if err != nil {
 		exitGracefully(err)
 	}
 
 	// run migrations
-	err = doMigrate("up", "")
+	err = doMigrate("up", "", migrateOptions{})
+	if err != nil {
+		exitGracefully(err)
+	}
+
+	err = copyDataDatabase()
 	if err != nil {
 		exitGracefully(err)
 	}
@@ -95,7 +106,7 @@ func doAuth() error {
 	color.Yellow("  - user and token models created")
 	color.Yellow("  - auth middleware created")
 	color.Yellow("")
-	color.Yellow("Don't forget to add user and token models in data/models.go, and to add appropriate middleware to your routes!")
+	color.Yellow("Don't forget to add user and token models in data/models.go, call data.UseDatabase(app.DB) at startup, and add appropriate middleware to your routes!")
 
 	return nil
 }
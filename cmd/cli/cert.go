@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// doCert creates a local development certificate authority (or reuses an existing one), and a
+// certificate signed by it for localhost, SERVER_NAME and any comma separated hosts in extraHosts
+func doCert(extraHosts string) error {
+	dir := nav.RootPath + "/tls"
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	caFile := dir + "/navitas-ca.pem"
+	caKeyFile := dir + "/navitas-ca-key.pem"
+
+	var ca *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+
+	if fileExists(caFile) && fileExists(caKeyFile) {
+		ca, caKey, err = loadCA(caFile, caKeyFile)
+		if err != nil {
+			return err
+		}
+		color.Yellow("  - using existing development CA %s", caFile)
+	} else {
+		ca, caKey, err = createCA(caFile, caKeyFile)
+		if err != nil {
+			return err
+		}
+		color.Yellow("  - created development CA %s", caFile)
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if nav.Config.ServerName != "" && nav.Config.ServerName != "localhost" {
+		hosts = append(hosts, nav.Config.ServerName)
+	}
+	for _, h := range strings.Split(extraHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"navitas development certificate"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	err = writePEM(dir+"/cert.pem", "CERTIFICATE", der, 0644)
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = writePEM(dir+"/key.pem", "EC PRIVATE KEY", keyBytes, 0600)
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("  - created certificate for %s", strings.Join(hosts, ", "))
+	color.Yellow("")
+	color.Yellow("Add the following to .env, and trust %s in your browser or operating system:", caFile)
+	color.Yellow("  TLS_CERT_FILE=tls/cert.pem")
+	color.Yellow("  TLS_KEY_FILE=tls/key.pem")
+	color.Yellow("  SECURE=true")
+
+	return nil
+}
+
+func createCA(caFile, caKeyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"navitas development CA"}, CommonName: "navitas development CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writePEM(caFile, "CERTIFICATE", der, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writePEM(caKeyFile, "EC PRIVATE KEY", keyBytes, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ca, key, nil
+}
+
+func loadCA(caFile, caKeyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, nil, errors.New(caFile + " is not a pem encoded certificate")
+	}
+
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := os.ReadFile(caKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, errors.New(caKeyFile + " is not a pem encoded key")
+	}
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ca, key, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func writePEM(fileName, blockType string, data []byte, mode os.FileMode) error {
+	out := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data})
+	return os.WriteFile(fileName, out, mode)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmozi/navitas"
+)
+
+func TestDoCert(t *testing.T) {
+	root := t.TempDir()
+	nav = navitas.Navitas{RootPath: root}
+	nav.Config.ServerName = "app.test"
+
+	if err := doCert("api.app.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(filepath.Join(root, "tls", "cert.pem"), filepath.Join(root, "tls", "key.pem"))
+	if err != nil {
+		t.Fatal("expected a usable certificate and key:", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPEM, err := os.ReadFile(filepath.Join(root, "tls", "navitas-ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatal("expected the development CA to be a pem encoded certificate")
+	}
+
+	for _, host := range []string{"localhost", "127.0.0.1", "app.test", "api.app.test"} {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		if err != nil {
+			t.Errorf("expected the certificate to be valid for %s: %v", host, err)
+		}
+	}
+
+	// a second certificate is signed by the same CA
+	if err := doCert(""); err != nil {
+		t.Fatal(err)
+	}
+	again, err := os.ReadFile(filepath.Join(root, "tls", "navitas-ca.pem"))
+	if err != nil || string(again) != string(caPEM) {
+		t.Error("expected the existing development CA to be reused")
+	}
+}
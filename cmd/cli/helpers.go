@@ -6,55 +6,89 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/bmozi/navitas"
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
 )
 
 func setup(arg1, arg2 string) {
 	if arg1 != "new" && arg1 != "version" && arg1 != "help" {
-		err := godotenv.Load()
+		path, err := os.Getwd()
 		if err != nil {
 			exitGracefully(err)
 		}
 
-		path, err := os.Getwd()
+		// use the same layered .env files as the application, so both talk to the same database
+		_, err = navitas.LoadEnvFiles(path)
 		if err != nil {
 			exitGracefully(err)
 		}
 
+		cfg, err := navitas.LoadConfig()
+		if err != nil {
+			exitGracefully(err)
+		}
+
+		// --db=<name> points the command at a named database in place of the default one
+		defaultDatabase = cfg.Database
+		if databaseName != "" {
+			db, ok := cfg.Databases[databaseName]
+			if !ok {
+				exitGracefully(fmt.Errorf("no database named %q; list it in DATABASES and configure it with DB_%s_*", databaseName, strings.ToUpper(databaseName)))
+			}
+			cfg.Database = db
+		}
+
 		nav.RootPath = path
-		nav.DB.DatabaseType = os.Getenv("DATABASE_TYPE")
+		nav.Config = *cfg
+		nav.Environment = cfg.Environment
+		nav.DB.DatabaseType = cfg.Database.Type
 	}
 }
 
-func getDSN() string {
-	dbType := nav.DB.DatabaseType
+// takeDatabaseFlag removes --db=<name> or --db <name> from the arguments, and returns the name
+func takeDatabaseFlag() string {
+	name := ""
+	args := os.Args[:1]
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case strings.HasPrefix(arg, "--db="):
+			name = strings.TrimPrefix(arg, "--db=")
+		case arg == "--db" && i+1 < len(os.Args):
+			name = os.Args[i+1]
+			i++
+		default:
+			args = append(args, arg)
+		}
+	}
+	os.Args = args
+	return name
+}
 
-	if dbType == "pgx" {
-		dbType = "postgres"
+// withoutAutoMigrate returns the environment for a program run inside the application, with
+// auto migration turned off for the default database and every named one. The programs migrate
+// themselves, so the application must not migrate any database as it boots.
+func withoutAutoMigrate(environ []string) []string {
+	env := append(environ, "DATABASE_AUTO_MIGRATE=false")
+	for name := range nav.Config.Databases {
+		env = append(env, "DB_"+strings.ToUpper(name)+"_AUTO_MIGRATE=false")
 	}
+	return env
+}
 
-	if dbType == "postgres" {
-		var dsn string
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_PASS"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
-		} else {
-			dsn = fmt.Sprintf("postgres://%s@%s:%s/%s?sslmode=%s",
-				os.Getenv("DATABASE_USER"),
-				os.Getenv("DATABASE_HOST"),
-				os.Getenv("DATABASE_PORT"),
-				os.Getenv("DATABASE_NAME"),
-				os.Getenv("DATABASE_SSL_MODE"))
-		}
-		return dsn
+func getDSN() string {
+	return nav.BuildMigrationURL()
+}
+
+// dbDialect returns the sql dialect of the configured database, which selects the migration templates
+func dbDialect() string {
+	switch nav.DB.DatabaseType {
+	case "postgresql", "pgx":
+		return "postgres"
+	case "mariadb":
+		return "mysql"
 	}
-	return "mysql://" + nav.BuildDSN()
+	return nav.DB.DatabaseType
 }
 
 func showHelp() {
@@ -62,15 +96,31 @@ func showHelp() {
 
 	help                  - show the help commands
 	version               - print application version
+	down                  - puts the running server into maintenance mode (requires RPC_PORT)
+	up                    - takes the running server out of maintenance mode
 	migrate               - runs all up migrations that have not been run previously
-	migrate down          - reverses the most recent migration
+	migrate down [n|all]  - reverses the most recent migration, the last n migrations, or all of them
 	migrate reset         - runs all down migrations in reverse order, and then all up migrations
+	migrate to <version>  - migrates up or down to the given version
+	migrate force <ver>   - marks the given version as applied and clears the dirty flag, without running it
+	migrate status        - lists applied and pending migrations
+	migrate --dry-run     - prints the sql that migrate, or migrate to <version>, would run, without running it
+	migrate --allow-destructive - runs pending migrations that drop tables or columns, change column types or lock tables
+	db seed [name] [--fresh] - runs every seeder, or the named one, optionally after a migrate reset
+	db dump [file]        - writes the database schema and migration version to migrations/schema.sql, or file
+	db load [file]        - creates the schema from migrations/schema.sql, or file, in an empty database
 	make migration <name> - creates two new up and down migrations in the migrations folder
+	make migration --go <name> - creates a Go migration in the migrations folder
 	make auth             - creates and runs migrations for authentication tables, and creates models and middleware
 	make handler <name>   - creates a stub handler in the handlers directory
 	make model <name>     - creates a new model in the data directory
+	make seeder <name>    - creates a seeder in the seeders directory
 	make session          - creates a table in the database as a session store
 	make mail <name>      - creates two starter mail templates in the mail directory
+	make cert [hosts]     - creates a local development CA and a certificate for localhost (and hosts) in tls/
+
+	migrate, db dump, db load and make migration take --db=<name> to use a named database from
+	DATABASES, whose migrations are in migrations/<name> unless DB_<NAME>_MIGRATIONS says otherwise
 	
 	`)
 }
@@ -10,15 +10,26 @@ import (
 
 var nav navitas.Navitas
 
+// databaseName is the named database given with --db, or empty for the default one
+var databaseName string
+
+// defaultDatabase is the default database's config, kept when --db swaps in a named one
+var defaultDatabase navitas.DatabaseConfig
+
 const version = "1.0.0"
 
 func main() {
 	var message string
+	databaseName = takeDatabaseFlag()
 	arg1, arg2, arg3, err := validateInput()
 	if err != nil {
 		exitGracefully(err)
 	}
 
+	if databaseName != "" && !namedDatabaseCommand(arg1, arg2) {
+		exitGracefully(errors.New("--db only works with migrate, db dump, db load and make migration"))
+	}
+
 	setup(arg1, arg2)
 
 	switch arg1 {
@@ -41,14 +52,31 @@ func main() {
 		color.Yellow("Application version: " + version)
 
 	case "migrate":
-		if arg2 == "" {
-			arg2 = "up"
+		arg2, arg3, opts := migrateArgs(os.Args[2:])
+		err = doMigrate(arg2, arg3, opts)
+		if err != nil {
+			exitGracefully(err)
+		}
+		if arg2 != "status" && !opts.dryRun {
+			message = "Migrations complete!"
+		}
+
+	case "db":
+		switch arg2 {
+		case "seed":
+			err = doSeed(os.Args[3:])
+			message = "Seeding complete!"
+		case "dump":
+			err = doDump(arg3)
+		case "load":
+			err = doLoad(arg3)
+			message = "Schema loaded!"
+		default:
+			exitGracefully(errors.New("db requires a subcommand: (seed|dump|load)"))
 		}
-		err = doMigrate(arg2, arg3)
 		if err != nil {
 			exitGracefully(err)
 		}
-		message = "Migrations complete!"
 
 	case "make":
 		if arg2 == "" {
@@ -66,6 +94,20 @@ func main() {
 	exitGracefully(nil, message)
 }
 
+// namedDatabaseCommand reports whether a command can run against a named database. The rest
+// create application code or tables that belong with the default database.
+func namedDatabaseCommand(arg1, arg2 string) bool {
+	switch arg1 {
+	case "migrate":
+		return true
+	case "db":
+		return arg2 == "dump" || arg2 == "load"
+	case "make":
+		return arg2 == "migration"
+	}
+	return false
+}
+
 func validateInput() (string, string, string, error) {
 	var arg1, arg2, arg3 string
 
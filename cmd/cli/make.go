@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
 	"time"
 
@@ -18,18 +19,39 @@ func doMake(arg2, arg3 string) error {
 		rnd := nav.RandomString(32)
 		color.Yellow("32 character encryption key: %s", rnd)
 
+	case "cert":
+		err := doCert(arg3)
+		if err != nil {
+			exitGracefully(err)
+		}
+
 	case "migration":
-		dbType := nav.DB.DatabaseType
+		dbType := dbDialect()
+		if arg3 == "--go" {
+			if len(os.Args) < 5 {
+				exitGracefully(errors.New("you must give the migration a name"))
+			}
+			err := doGoMigration(os.Args[4])
+			if err != nil {
+				exitGracefully(err)
+			}
+			break
+		}
 		if arg3 == "" {
 			exitGracefully(errors.New("you must give the migration a name"))
 		}
 
 		fileName := fmt.Sprintf("%d_%s", time.Now().UnixMicro(), arg3)
 
-		upFile := nav.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
-		downFile := nav.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+		err := os.MkdirAll(nav.MigrationsPath(), 0755)
+		if err != nil {
+			exitGracefully(err)
+		}
+
+		upFile := nav.MigrationsPath() + "/" + fileName + "." + dbType + ".up.sql"
+		downFile := nav.MigrationsPath() + "/" + fileName + "." + dbType + ".down.sql"
 
-		err := copyFileFromTemplate("templates/migrations/migration."+dbType+".up.sql", upFile)
+		err = copyFileFromTemplate("templates/migrations/migration."+dbType+".up.sql", upFile)
 		if err != nil {
 			exitGracefully(err)
 		}
@@ -100,11 +122,22 @@ func doMake(arg2, arg3 string) error {
 		model = strings.ReplaceAll(model, "$MODELNAME$", strcase.ToCamel(modelName))
 		model = strings.ReplaceAll(model, "$TABLENAME$", tableName)
 
+		err = copyDataDatabase()
+		if err != nil {
+			exitGracefully(err)
+		}
+
 		err = copyDataToFile([]byte(model), fileName)
 		if err != nil {
 			exitGracefully(err)
 		}
 
+	case "seeder":
+		err := doSeeder(arg3)
+		if err != nil {
+			exitGracefully(err)
+		}
+
 	case "session":
 		err := doSessionTable()
 		if err != nil {
@@ -113,3 +146,58 @@ func doMake(arg2, arg3 string) error {
 	}
 	return nil
 }
+
+// doGoMigration creates a Go migration in the migrations folder, along with the file that collects
+// the migrations for navitas.WithGoMigrations and the program that runs them, if they do not exist
+// yet
+func doGoMigration(name string) error {
+	version := time.Now().UnixMicro()
+	name = strcase.ToSnake(name)
+
+	err := copyGoMigrationRegistry()
+	if err != nil {
+		return err
+	}
+
+	err = copyMigrateProgram()
+	if err != nil {
+		return err
+	}
+
+	data, err := templateFS.ReadFile("templates/migrations/migration.go.txt")
+	if err != nil {
+		return err
+	}
+
+	migration := string(data)
+	migration = strings.ReplaceAll(migration, "$VERSION$", fmt.Sprintf("%d", version))
+	migration = strings.ReplaceAll(migration, "$NAME$", name)
+
+	fileName := fmt.Sprintf("%s/%d_%s.go", nav.MigrationsPath(), version, name)
+	return copyDataToFile([]byte(migration), fileName)
+}
+
+// copyGoMigrationRegistry creates the file that collects the Go migrations in the migrations
+// folder, if it does not exist yet
+func copyGoMigrationRegistry() error {
+	err := os.MkdirAll(nav.MigrationsPath(), 0755)
+	if err != nil {
+		return err
+	}
+
+	registry := nav.MigrationsPath() + "/migrations.go"
+	if fileExists(registry) {
+		return nil
+	}
+	return copyFileFromTemplate("templates/migrations/migrations.go.txt", registry)
+}
+
+// copyDataDatabase creates data/database.go, which holds the connection the generated models use,
+// if it does not exist yet
+func copyDataDatabase() error {
+	fileName := nav.RootPath + "/data/database.go"
+	if fileExists(fileName) {
+		return nil
+	}
+	return copyFileFromTemplate("templates/data/database.go.txt", fileName)
+}
@@ -1,7 +1,95 @@
 package main
 
-func doMigrate(arg2, arg3 string) error {
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bmozi/navitas"
+	"github.com/fatih/color"
+)
+
+// migrateOptions are the flags given to the migrate command
+type migrateOptions struct {
+	dryRun           bool // print the sql that would run, without running it
+	allowDestructive bool // run migrations even when the linter reports destructive statements
+}
+
+// migrateArgs separates the flags given to migrate from its subcommand and argument
+func migrateArgs(args []string) (string, string, migrateOptions) {
+	var opts migrateOptions
+	var positional []string
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "dry-run":
+			opts.dryRun = true
+		case "allow-destructive":
+			opts.allowDestructive = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	positional = append(positional, "", "")
+	if positional[0] == "" {
+		positional[0] = "up"
+	}
+	return positional[0], positional[1], opts
+}
+
+func doMigrate(arg2, arg3 string, opts migrateOptions) error {
 	dsn := getDSN()
+	color.Yellow("Migrating %s database %s", nav.Environment, nav.Config.Database.Name)
+
+	goVersions, err := registerGoMigrations()
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun && arg2 != "up" && arg2 != "to" {
+		return errors.New("--dry-run works with migrate up and migrate to")
+	}
+
+	// pending up migrations are shown for a dry run, and checked for destructive statements
+	// before they run
+	if arg2 == "up" || arg2 == "to" {
+		target := uint(math.MaxUint)
+		if arg2 == "to" {
+			version, err := strconv.ParseUint(arg3, 10, 64)
+			if err != nil {
+				return errors.New("migrate to requires a migration version")
+			}
+			target = uint(version)
+		}
+
+		if opts.dryRun {
+			return showPendingMigrations(dsn, target)
+		}
+
+		err := checkPendingMigrations(dsn, target, opts.allowDestructive)
+		if err != nil {
+			return err
+		}
+	}
+
+	// go migrations are compiled into the application, so commands that run one are handed to it
+	if len(goVersions) > 0 {
+		runsGo, err := runsGoMigration(dsn, arg2, arg3, goVersions)
+		if err != nil {
+			return err
+		}
+		if runsGo {
+			return appMigrate(migrateProgramArgs(arg2, arg3)...)
+		}
+	}
 
 	// run the migration command
 	switch arg2 {
@@ -12,17 +100,28 @@ func doMigrate(arg2, arg3 string) error {
 		}
 
 	case "down":
-		if arg3 == "all" {
+		switch arg3 {
+		case "all":
 			err := nav.MigrateDownAll(dsn)
 			if err != nil {
 				return err
 			}
-		} else {
-			err := nav.Steps(-1, dsn)
+		case "":
+			err := nav.MigrateDown(1, dsn)
+			if err != nil {
+				return err
+			}
+		default:
+			steps, err := strconv.Atoi(arg3)
+			if err != nil {
+				return errors.New("migrate down takes a number of steps, or all")
+			}
+			err = nav.MigrateDown(steps, dsn)
 			if err != nil {
 				return err
 			}
 		}
+
 	case "reset":
 		err := nav.MigrateDownAll(dsn)
 		if err != nil {
@@ -32,8 +131,361 @@ func doMigrate(arg2, arg3 string) error {
 		if err != nil {
 			return err
 		}
+
+	case "to":
+		version, err := strconv.ParseUint(arg3, 10, 64)
+		if err != nil {
+			return errors.New("migrate to requires a migration version")
+		}
+		err = nav.MigrateTo(uint(version), dsn)
+		if err != nil {
+			return err
+		}
+
+	case "force":
+		version, err := strconv.Atoi(arg3)
+		if err != nil {
+			return errors.New("migrate force requires a migration version, or -1 for none")
+		}
+		err = nav.MigrateForce(version, dsn)
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		return showMigrationStatus(dsn)
+
 	default:
 		showHelp()
 	}
 	return nil
 }
+
+func showMigrationStatus(dsn string) error {
+	status, err := nav.MigrationStatus(dsn)
+	if err != nil {
+		return err
+	}
+
+	if len(status.Migrations) == 0 {
+		color.Yellow("No migrations found")
+		return nil
+	}
+
+	for _, m := range status.Migrations {
+		line := fmt.Sprintf("  %-20d %s", m.Version, m.Name)
+		switch {
+		case m.Dirty:
+			color.Red("%s  dirty", line)
+		case m.Applied:
+			color.Green("%s  applied", line)
+		default:
+			color.Yellow("%s  pending", line)
+		}
+	}
+
+	if status.Dirty {
+		color.Red("Migration %d failed part way through. Fix it, then run: navitas migrate force <version>", status.Version)
+	}
+	return nil
+}
+
+// showPendingMigrations prints the sql of each pending migration up to target, in the order they
+// would run, followed by any destructive statements they contain
+func showPendingMigrations(dsn string, target uint) error {
+	pending, err := pendingMigrations(dsn, target)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		color.Yellow("No pending migrations")
+		return nil
+	}
+
+	for _, m := range pending {
+		color.Green("-- %d_%s", m.Version, m.Name)
+		if m.Go {
+			fmt.Println("-- Go migration, run by the application")
+		} else {
+			fmt.Println(strings.TrimSpace(m.SQL))
+		}
+		fmt.Println()
+	}
+
+	for _, issue := range nav.LintMigrations(pending) {
+		color.Red("Warning: %s", issue)
+	}
+	return nil
+}
+
+// checkPendingMigrations refuses to run pending migrations up to target that contain destructive
+// statements, unless they are allowed
+func checkPendingMigrations(dsn string, target uint, allow bool) error {
+	pending, err := pendingMigrations(dsn, target)
+	if err != nil {
+		return err
+	}
+
+	issues := nav.LintMigrations(pending)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	for _, issue := range issues {
+		if allow {
+			color.Yellow("Warning: %s", issue)
+		} else {
+			color.Red("%s\n    %s", issue, issue.Statement)
+		}
+	}
+	if allow {
+		return nil
+	}
+	return errors.New("pending migrations contain destructive statements; review them with migrate --dry-run, then run again with --allow-destructive")
+}
+
+func pendingMigrations(dsn string, target uint) ([]navitas.PendingMigration, error) {
+	pending, err := nav.PendingMigrations(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		if m.Version > target {
+			return pending[:i], nil
+		}
+	}
+	return pending, nil
+}
+
+// appMigrate runs a migrate command that includes Go migrations. It is a variable so that tests
+// can stand in for the application.
+var appMigrate = runAppMigrations
+
+// registerGoMigrations adds the Go migrations in the migrations folder to nav, so that their
+// versions are known when reading and changing the migration version, and returns their versions.
+// Only the application can run their functions. Files are named <version>_<name>.go.
+func registerGoMigrations() (map[uint]bool, error) {
+	files, err := filepath.Glob(filepath.Join(nav.MigrationsPath(), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[uint]bool)
+	for _, f := range files {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(filepath.Base(f), ".go"), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		nav.AddGoMigration(navitas.GoMigration{Version: uint(version), Name: name})
+		versions[uint(version)] = true
+	}
+	return versions, nil
+}
+
+// runsGoMigration reports whether a migrate command would run the up or down function of any of
+// the Go migrations in goVersions
+func runsGoMigration(dsn, arg2, arg3 string, goVersions map[uint]bool) (bool, error) {
+	if arg2 == "reset" {
+		return true, nil
+	}
+	if arg2 != "up" && arg2 != "down" && arg2 != "to" {
+		return false, nil
+	}
+
+	status, err := nav.MigrationStatus(dsn)
+	if err != nil {
+		return false, err
+	}
+
+	var applied, pending []navitas.Migration
+	for _, m := range status.Migrations {
+		if m.Applied {
+			applied = append(applied, m)
+		} else {
+			pending = append(pending, m)
+		}
+	}
+
+	// the migrations that would run, as the up migrations up to a version, or the down
+	// migrations after one
+	var runs []navitas.Migration
+	switch arg2 {
+	case "up":
+		runs = pending
+
+	case "down":
+		steps := 1
+		if arg3 == "all" {
+			steps = len(applied)
+		} else if arg3 != "" {
+			steps, err = strconv.Atoi(arg3)
+			if err != nil {
+				return false, nil
+			}
+		}
+		runs = applied[max(len(applied)-steps, 0):]
+
+	case "to":
+		version, err := strconv.ParseUint(arg3, 10, 64)
+		if err != nil {
+			return false, nil
+		}
+		for _, m := range status.Migrations {
+			if (!m.Applied && m.Version <= uint(version)) || (m.Applied && m.Version > uint(version)) {
+				runs = append(runs, m)
+			}
+		}
+	}
+
+	for _, m := range runs {
+		if goVersions[m.Version] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// migrateProgramArgs returns the arguments for the cmd/migrate program, pointing it at the named
+// database given with --db
+func migrateProgramArgs(arg2, arg3 string) []string {
+	var args []string
+	if databaseName != "" {
+		args = append(args, "--db="+databaseName)
+	}
+	return append(args, arg2, arg3)
+}
+
+// runAppMigrations runs a migrate command with the application's cmd/migrate program, which has
+// the Go migrations compiled in. The program is created if it does not exist yet.
+func runAppMigrations(args ...string) error {
+	err := copyMigrateProgram()
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("Running migrations with the application, since they include Go migrations")
+
+	cmdArgs := []string{"run", "./cmd/migrate"}
+	for _, arg := range args {
+		if arg != "" {
+			cmdArgs = append(cmdArgs, arg)
+		}
+	}
+
+	cmd := exec.Command("go", cmdArgs...)
+	cmd.Dir = nav.RootPath
+	cmd.Env = withoutAutoMigrate(os.Environ())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// copyMigrateProgram creates cmd/migrate/main.go, the program that runs Go migrations, if it does
+// not exist yet, and brings its list of Go migration packages up to date
+func copyMigrateProgram() error {
+	module, err := appModule()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(nav.RootPath+"/cmd/migrate", 0755)
+	if err != nil {
+		return err
+	}
+
+	fileName := nav.RootPath + "/cmd/migrate/main.go"
+	if !fileExists(fileName) {
+		data, err := templateFS.ReadFile("templates/migrations/main.go.txt")
+		if err != nil {
+			return err
+		}
+
+		err = copyDataToFile([]byte(strings.ReplaceAll(string(data), "$APPNAME$", module)), fileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeGoMigrationPackages(module)
+}
+
+// writeGoMigrationPackages creates cmd/migrate/databases.go, which maps the default database and
+// each named database whose migrations folder holds Go migrations to the package that collects
+// them. It is rewritten every time, since databases and their Go migrations come and go.
+func writeGoMigrationPackages(module string) error {
+	databases := map[string]navitas.DatabaseConfig{"": defaultDatabase}
+	for name, db := range nav.Config.Databases {
+		databases[name] = db
+	}
+
+	names := make([]string, 0, len(databases))
+	for name := range databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var imports, entries strings.Builder
+	for _, name := range names {
+		folder := migrationsFolder(databases[name])
+		if !fileExists(filepath.Join(folder, "migrations.go")) {
+			continue
+		}
+
+		pkg, err := goPackage(module, folder)
+		if err != nil {
+			return err
+		}
+
+		alias := "migrations"
+		if name != "" {
+			alias = "migrations_" + strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) {
+					return r
+				}
+				return '_'
+			}, name)
+		}
+		fmt.Fprintf(&imports, "\t%s %q\n", alias, pkg)
+		fmt.Fprintf(&entries, "\t%q: %s.All,\n", name, alias)
+	}
+
+	source := fmt.Sprintf(`// Code generated by navitas migrate. DO NOT EDIT.
+
+package main
+
+import (
+	"github.com/bmozi/navitas"
+
+%s)
+
+// goMigrations holds the Go migrations of each database that has them, by the name given with
+// --db. The default database has the empty name.
+var goMigrations = map[string]func() []navitas.GoMigration{
+%s}
+`, imports.String(), entries.String())
+
+	data, err := format.Source([]byte(source))
+	if err != nil {
+		return err
+	}
+	return copyDataToFile(data, nav.RootPath+"/cmd/migrate/databases.go")
+}
+
+// goPackage returns the import path of the application package in folder
+func goPackage(module, folder string) (string, error) {
+	rel, err := filepath.Rel(nav.RootPath, folder)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("the Go migrations in %s must be in a package inside the application", folder)
+	}
+	return module + "/" + filepath.ToSlash(rel), nil
+}
+
+// migrationsFolder returns the migrations folder of db, resolved against the application's root
+func migrationsFolder(db navitas.DatabaseConfig) string {
+	app := navitas.Navitas{RootPath: nav.RootPath}
+	app.Config.Database = db
+	return app.MigrationsPath()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bmozi/navitas"
+)
+
+func writeMigration(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDoMigrate_PastGoMigration(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "migrations")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeMigration(t, dir, "1_users.sqlite.up.sql", "create table users (name text);")
+	writeMigration(t, dir, "1_users.sqlite.down.sql", "drop table users;")
+	writeMigration(t, dir, "2_backfill_users.go", "package migrations\n")
+	writeMigration(t, dir, "3_posts.sqlite.up.sql", "create table posts (title text);")
+	writeMigration(t, dir, "3_posts.sqlite.down.sql", "drop table posts;")
+
+	nav = navitas.Navitas{RootPath: root}
+	nav.Config.Database = navitas.DatabaseConfig{Type: "sqlite", Name: "app.db"}
+	nav.DB.DatabaseType = "sqlite"
+	dsn := getDSN()
+
+	pool, err := sql.Open("sqlite", filepath.Join(root, "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// stand in for cmd/migrate, which has the Go migration compiled in
+	var calls [][]string
+	appMigrate = func(args ...string) error {
+		calls = append(calls, args)
+		if args[0] != "to" {
+			return nil
+		}
+		app := &navitas.Navitas{RootPath: root, Config: nav.Config, DB: navitas.Database{DatabaseType: "sqlite", Pool: pool}}
+		app.AddGoMigration(navitas.GoMigration{
+			Version: 2,
+			Name:    "backfill_users",
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("insert into users (name) values ('backfilled')")
+				return err
+			},
+		})
+		return app.MigrateTo(2, dsn)
+	}
+	defer func() { appMigrate = runAppMigrations }()
+
+	// migrating to the Go migration runs it in the application
+	if err := doMigrate("to", "2", migrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0][0] != "to" || calls[0][1] != "2" {
+		t.Fatalf("expected migrate to 2 to be handed to the application, got %v", calls)
+	}
+
+	var name string
+	if err := pool.QueryRow("select name from users").Scan(&name); err != nil || name != "backfilled" {
+		t.Fatalf("expected the Go migration to have run, got %q, %v", name, err)
+	}
+
+	// the database is now at a Go migration's version, and the sql migration after it runs here
+	if err := doMigrate("status", "", migrateOptions{}); err != nil {
+		t.Fatal("unexpected error showing status at a Go migration:", err)
+	}
+	if err := doMigrate("up", "", migrateOptions{}); err != nil {
+		t.Fatal("unexpected error migrating past a Go migration:", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected sql migrations to run without the application, got %v", calls)
+	}
+
+	status, err := nav.MigrationStatus(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 3 || len(status.Pending()) != 0 {
+		t.Errorf("expected every migration to be applied, got version %d with %d pending", status.Version, len(status.Pending()))
+	}
+	if _, err := pool.Exec("insert into posts (title) values ('hello')"); err != nil {
+		t.Error("expected the posts table to exist:", err)
+	}
+
+	// going back down over the Go migration is handed to the application again
+	if err := doMigrate("down", "2", migrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[1][0] != "down" {
+		t.Errorf("expected migrate down 2 to be handed to the application, got %v", calls)
+	}
+}
+
+func TestDoMigrate_NamedDatabaseGoMigration(t *testing.T) {
+	root := t.TempDir()
+	writeMigration(t, root, "go.mod", "module example.com/app\n\ngo 1.22\n")
+	if err := os.Mkdir(filepath.Join(root, "migrations"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeMigration(t, filepath.Join(root, "migrations"), "1_users.sqlite.up.sql", "create table users (name text);")
+	writeMigration(t, filepath.Join(root, "migrations"), "1_users.sqlite.down.sql", "drop table users;")
+
+	dir := filepath.Join(root, "migrations", "analytics")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeMigration(t, dir, "1_events.sqlite.up.sql", "create table events (name text);")
+	writeMigration(t, dir, "1_events.sqlite.down.sql", "drop table events;")
+	writeMigration(t, dir, "2_backfill_events.go", "package migrations\n")
+	writeMigration(t, dir, "migrations.go", "package migrations\n")
+
+	// as setup does for --db=analytics
+	analytics := navitas.DatabaseConfig{Type: "sqlite", Name: "analytics.db", Migrations: "migrations/analytics"}
+	nav = navitas.Navitas{RootPath: root}
+	nav.Config.Database = analytics
+	nav.Config.Databases = map[string]navitas.DatabaseConfig{"analytics": analytics}
+	nav.DB.DatabaseType = "sqlite"
+	defaultDatabase = navitas.DatabaseConfig{Type: "sqlite", Name: "app.db"}
+	databaseName = "analytics"
+	defer func() { databaseName, defaultDatabase = "", navitas.DatabaseConfig{} }()
+
+	var calls [][]string
+	appMigrate = func(args ...string) error {
+		calls = append(calls, args)
+		return copyMigrateProgram()
+	}
+	defer func() { appMigrate = runAppMigrations }()
+
+	if err := doMigrate("up", "", migrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the application is pointed at the named database, so it runs that database's Go migrations
+	if len(calls) != 1 || len(calls[0]) < 2 || calls[0][0] != "--db=analytics" || calls[0][1] != "up" {
+		t.Fatalf("expected migrate up to be handed to the application with --db=analytics, got %v", calls)
+	}
+
+	if !fileExists(filepath.Join(root, "cmd", "migrate", "main.go")) {
+		t.Error("expected the migrate program to be created")
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "cmd", "migrate", "databases.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := string(data)
+	if !strings.Contains(registry, `migrations_analytics "example.com/app/migrations/analytics"`) ||
+		!strings.Contains(registry, `"analytics": migrations_analytics.All`) {
+		t.Errorf("expected the analytics Go migrations to be registered, got\n%s", registry)
+	}
+	if strings.Contains(registry, `"example.com/app/migrations"`) {
+		t.Errorf("expected the default database, which has no Go migrations, to be left out, got\n%s", registry)
+	}
+}
+
+func TestWithoutAutoMigrate(t *testing.T) {
+	nav = navitas.Navitas{}
+	nav.Config.Databases = map[string]navitas.DatabaseConfig{"analytics": {}, "reporting": {}}
+	defer func() { nav = navitas.Navitas{} }()
+
+	env := strings.Join(withoutAutoMigrate([]string{"PATH=/bin"}), " ")
+	for _, e := range []string{"DATABASE_AUTO_MIGRATE=false", "DB_ANALYTICS_AUTO_MIGRATE=false", "DB_REPORTING_AUTO_MIGRATE=false"} {
+		if !strings.Contains(env, e) {
+			t.Errorf("expected the environment to contain %s, got %s", e, env)
+		}
+	}
+}
+
+func TestMigrationTemplatesForEveryDialect(t *testing.T) {
+	for _, dialect := range []string{"mysql", "postgres", "sqlite"} {
+		for _, direction := range []string{"up", "down"} {
+			name := "templates/migrations/migration." + dialect + "." + direction + ".sql"
+			if _, err := templateFS.ReadFile(name); err != nil {
+				t.Errorf("expected %s to exist: %v", name, err)
+			}
+		}
+	}
+}
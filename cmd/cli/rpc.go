@@ -3,13 +3,12 @@ package main
 import (
 	"fmt"
 	"net/rpc"
-	"os"
 
 	"github.com/fatih/color"
 )
 
 func rpcClient(inMaintenanceMode bool) {
-	rpcPort := os.Getenv("RPC_PORT")
+	rpcPort := nav.Config.RPCPort
 	c, err := rpc.Dial("tcp", "127.0.0.1:"+rpcPort)
 	if err != nil {
 		exitGracefully(err)
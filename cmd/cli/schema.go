@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+// defaultSchemaFile is where db dump writes the schema, and db load reads it, when no file is given.
+// It sits in the migrations folder, but is not a versioned file, so the migration runner ignores it.
+const defaultSchemaFile = "schema.sql"
+
+// doDump writes the current database schema and migration version to fileName
+func doDump(fileName string) error {
+	fileName = schemaFile(fileName)
+
+	f, err := os.CreateTemp(filepath.Dir(fileName), ".schema-*.sql")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = nav.DumpSchema(context.Background(), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the dump replaces the old schema file only once it has been written in full
+	err = os.Rename(f.Name(), fileName)
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("Schema written to %s", fileName)
+	return nil
+}
+
+// doLoad creates the schema in fileName in an empty database
+func doLoad(fileName string) error {
+	fileName = schemaFile(fileName)
+
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New(fileName + " does not exist; create it with: navitas db dump")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	color.Yellow("Loading %s into %s database %s", fileName, nav.Environment, nav.Config.Database.Name)
+	return nav.LoadSchema(context.Background(), f)
+}
+
+func schemaFile(fileName string) string {
+	if fileName == "" {
+		return filepath.Join(nav.MigrationsPath(), defaultSchemaFile)
+	}
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(nav.RootPath, fileName)
+	}
+	return fileName
+}
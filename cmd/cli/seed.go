@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/iancoleman/strcase"
+)
+
+// doSeeder creates a seeder in the seeders folder, along with the file that collects the seeders
+// and the program that runs them, if they do not exist yet
+func doSeeder(name string) error {
+	if name == "" {
+		return errors.New("you must give the seeder a name")
+	}
+	name = strcase.ToSnake(name)
+
+	err := os.MkdirAll(nav.RootPath+"/seeders", 0755)
+	if err != nil {
+		return err
+	}
+
+	// like migrations, seeders are ordered by the time they were created, which prefixes the file
+	// name so the folder lists them in the order they run
+	order := time.Now().UnixMicro()
+	files, _ := filepath.Glob(nav.RootPath + "/seeders/*.go")
+	for _, f := range files {
+		base := strings.TrimSuffix(filepath.Base(f), ".go")
+		if prefix, rest, ok := strings.Cut(base, "_"); base == name || (ok && rest == name && strings.Trim(prefix, "0123456789") == "") {
+			return errors.New(f + " already exists!")
+		}
+	}
+	fileName := fmt.Sprintf("%s/seeders/%d_%s.go", nav.RootPath, order, name)
+
+	if !fileExists(nav.RootPath + "/seeders/seeders.go") {
+		err = copyFileFromTemplate("templates/seeders/seeders.go.txt", nav.RootPath+"/seeders/seeders.go")
+		if err != nil {
+			return err
+		}
+	}
+
+	if !fileExists(nav.RootPath + "/cmd/seed/main.go") {
+		module, err := appModule()
+		if err != nil {
+			return err
+		}
+
+		// the seed program registers the Go migrations, so that --fresh runs them too
+		err = copyGoMigrationRegistry()
+		if err != nil {
+			return err
+		}
+		migrations, err := goPackage(module, nav.MigrationsPath())
+		if err != nil {
+			return err
+		}
+
+		data, err := templateFS.ReadFile("templates/seeders/main.go.txt")
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(nav.RootPath+"/cmd/seed", 0755)
+		if err != nil {
+			return err
+		}
+
+		program := strings.ReplaceAll(string(data), "$APPNAME$", module)
+		program = strings.ReplaceAll(program, "$MIGRATIONS$", migrations)
+		err = copyDataToFile([]byte(program), nav.RootPath+"/cmd/seed/main.go")
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := templateFS.ReadFile("templates/seeders/seeder.go.txt")
+	if err != nil {
+		return err
+	}
+
+	seeder := strings.ReplaceAll(string(data), "$SEEDERNAME$", name)
+	seeder = strings.ReplaceAll(seeder, "$ORDER$", fmt.Sprintf("%d", order))
+	return copyDataToFile([]byte(seeder), fileName)
+}
+
+// doSeed runs the application's seeders. Seeders are application code, so they are run by
+// compiling the seed program in cmd/seed rather than by this tool.
+func doSeed(args []string) error {
+	if !fileExists(nav.RootPath + "/cmd/seed/main.go") {
+		return errors.New("no seeders found; create one with: navitas make seeder <name>")
+	}
+
+	cmdArgs := []string{"run", "./cmd/seed"}
+	var names []string
+	for _, arg := range args {
+		if arg == "--fresh" || arg == "-fresh" {
+			cmdArgs = append(cmdArgs, "-fresh")
+			continue
+		}
+		names = append(names, arg)
+	}
+	cmdArgs = append(cmdArgs, names...)
+
+	color.Yellow("Seeding %s database %s", nav.Environment, nav.Config.Database.Name)
+
+	cmd := exec.Command("go", cmdArgs...)
+	cmd.Dir = nav.RootPath
+	// --fresh runs the migrations itself
+	cmd.Env = withoutAutoMigrate(os.Environ())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// appModule returns the module path from the application's go.mod
+func appModule() (string, error) {
+	data, err := os.ReadFile(nav.RootPath + "/go.mod")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", errors.New("go.mod does not declare a module")
+}
@@ -6,20 +6,12 @@ import (
 )
 
 func doSessionTable() error {
-	dbType := nav.DB.DatabaseType
-
-	if dbType == "mariadb" {
-		dbType = "mysql"
-	}
-
-	if dbType == "postgresql" {
-		dbType = "postgres"
-	}
+	dbType := dbDialect()
 
 	fileName := fmt.Sprintf("%d_create_sessions_table", time.Now().UnixMicro())
 
-	upFile := nav.RootPath + "/migrations/" + fileName + "." + dbType + ".up.sql"
-	downFile := nav.RootPath + "/migrations/" + fileName + "." + dbType + ".down.sql"
+	upFile := nav.MigrationsPath() + "/" + fileName + "." + dbType + ".up.sql"
+	downFile := nav.MigrationsPath() + "/" + fileName + "." + dbType + ".down.sql"
 
 	err := copyFileFromTemplate("templates/migrations/"+dbType+"_session.sql", upFile)
 	if err != nil {
@@ -31,7 +23,7 @@ func doSessionTable() error {
 		exitGracefully(err)
 	}
 
-	err = doMigrate("up", "")
+	err = doMigrate("up", "", migrateOptions{})
 	if err != nil {
 		exitGracefully(err)
 	}
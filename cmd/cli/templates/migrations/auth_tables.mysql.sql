@@ -1,5 +1,3 @@
-drop table if exists users cascade;
-
 CREATE TABLE `users` (
     `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
     `first_name` varchar(255) CHARACTER SET utf8 COLLATE utf8_unicode_ci NOT NULL,
@@ -14,8 +12,6 @@ CREATE TABLE `users` (
     KEY `users_email_index` (`email`)
 ) ENGINE=InnoDB AUTO_INCREMENT=17 DEFAULT CHARSET=utf8mb4;
 
-drop table if exists remember_tokens cascade;
-
 CREATE TABLE `remember_tokens` (
     `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
     `user_id` int(10) unsigned NOT NULL,
@@ -28,8 +24,6 @@ CREATE TABLE `remember_tokens` (
     CONSTRAINT `remember_tokens_user_id_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB AUTO_INCREMENT=21 DEFAULT CHARSET=utf8;
 
-drop table if exists tokens cascade;
-
 CREATE TABLE `tokens` (
     `id` int(11) NOT NULL AUTO_INCREMENT,
     `user_id` int(11) unsigned NOT NULL,
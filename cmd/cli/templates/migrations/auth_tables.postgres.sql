@@ -6,8 +6,6 @@ RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
 
-drop table if exists users cascade;
-
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
     first_name character varying(255) NOT NULL,
@@ -24,8 +22,6 @@ CREATE TRIGGER set_timestamp
     FOR EACH ROW
     EXECUTE PROCEDURE trigger_set_timestamp();
 
-drop table if exists remember_tokens;
-
 CREATE TABLE remember_tokens (
     id SERIAL PRIMARY KEY,
     user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
@@ -39,8 +35,6 @@ CREATE TRIGGER set_timestamp
     FOR EACH ROW
     EXECUTE PROCEDURE trigger_set_timestamp();
 
-drop table if exists tokens;
-
 CREATE TABLE tokens (
     id SERIAL PRIMARY KEY,
     user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
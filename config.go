@@ -0,0 +1,360 @@
+package navitas
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError is returned by LoadConfig when one or more settings are missing or invalid.
+// It lists every problem found, so a misconfigured deploy can be fixed in one pass.
+type ConfigError []ConfigProblem
+
+// ConfigProblem describes a single bad or missing environment variable
+type ConfigProblem struct {
+	Key     string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	problems := make([]string, 0, len(e))
+	for _, p := range e {
+		problems = append(problems, fmt.Sprintf("%s: %s", p.Key, p.Message))
+	}
+	return "invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// LoadConfig reads the application configuration from the environment. Defaults are applied
+// for optional values, and an error of type ConfigError is returned listing every key that
+// is missing or could not be parsed.
+func LoadConfig() (*Config, error) {
+	return loadConfig(os.Getenv)
+}
+
+func loadConfig(getenv func(string) string) (*Config, error) {
+	e := &envReader{getenv: getenv}
+
+	cfg := Config{
+		AppName:       e.str("APP_NAME", "navitas"),
+		AppURL:        e.str("APP_URL", ""),
+		Environment:   e.oneOf("NAVITAS_ENV", EnvDevelopment, environments...),
+		Debug:         e.bool("DEBUG", false),
+		Port:          e.port("PORT", "4000"),
+		ServerName:    e.str("SERVER_NAME", "localhost"),
+		Secure:        e.bool("SECURE", true),
+		Renderer:      e.oneOf("RENDERER", "jet", "jet", "go"),
+		EncryptionKey: e.str("KEY", ""),
+		SessionType:   e.oneOf("SESSION_TYPE", "cookie", "cookie", "redis", "mysql", "mariadb", "postgres", "postgresql", "sqlite"),
+		Cache:         e.oneOf("CACHE", "memory", "memory", "redis", "badger"),
+		RPCPort:       e.port("RPC_PORT", ""),
+	}
+	cfg.ShutdownTimeout = time.Duration(e.int("SHUTDOWN_TIMEOUT", 30)) * time.Second
+
+	cfg.Cookie = CookieConfig{
+		Name:     e.str("COOKIE_NAME", cfg.AppName),
+		Lifetime: e.int("COOKIE_LIFETIME", 60),
+		Persist:  e.bool("COOKIE_PERSIST", e.bool("COOKIE_PERSISTS", false)),
+		Secure:   e.bool("COOKIE_SECURE", false),
+		Domain:   e.str("COOKIE_DOMAIN", ""), // host only unless set
+	}
+
+	cfg.Database = e.database("DATABASE_", "migrations")
+
+	// named connections, such as a reporting warehouse, are listed in DATABASES and configured
+	// with DB_<NAME>_TYPE, DB_<NAME>_HOST and so on
+	for _, name := range e.list("DATABASES") {
+		name = strings.ToLower(name)
+		if !validDatabaseName(name) {
+			e.problem("DATABASES", fmt.Sprintf("%q must only contain letters, digits and underscores", name))
+			continue
+		}
+
+		prefix := "DB_" + strings.ToUpper(name) + "_"
+		e.required(prefix + "TYPE")
+		if cfg.Databases == nil {
+			cfg.Databases = make(map[string]DatabaseConfig)
+		}
+		cfg.Databases[name] = e.database(prefix, "migrations/"+name)
+	}
+
+	cfg.Redis = RedisConfig{
+		Host:     e.str("REDIS_HOST", ""),
+		Password: e.str("REDIS_PASSWORD", ""),
+		Prefix:   e.str("REDIS_PREFIX", cfg.AppName),
+	}
+	if cfg.Cache == "redis" || cfg.SessionType == "redis" {
+		e.required("REDIS_HOST")
+	}
+
+	cfg.MemoryCache = MemoryCacheConfig{
+		MaxEntries:      e.int("CACHE_MAX_ENTRIES", 10000),
+		MaxBytes:        int64(e.int("CACHE_MAX_BYTES", 64<<20)),
+		CleanupInterval: time.Duration(e.int("CACHE_CLEANUP_INTERVAL", 60)) * time.Second,
+	}
+
+	switch cfg.SessionType {
+	case "mysql", "mariadb", "postgres", "postgresql", "sqlite":
+		if cfg.Database.Type == "" {
+			e.problem("SESSION_TYPE", fmt.Sprintf("session type %q requires DATABASE_TYPE to be set", cfg.SessionType))
+		}
+	}
+
+	if cfg.EncryptionKey != "" && len(cfg.EncryptionKey) != 32 {
+		e.problem("KEY", "must be exactly 32 characters long")
+	}
+
+	cfg.Uploads = UploadConfig{
+		AllowedMimeTypes: e.list("ALLOWED_FILETYPES"),
+		MaxUploadSize:    int64(e.int("MAX_UPLOAD_SIZE", 10<<20)),
+	}
+
+	cfg.Maintenance = MaintenanceConfig{
+		RetryAfter:     e.int("MAINTENANCE_RETRY_AFTER", 300),
+		AllowedIPs:     e.list("MAINTENANCE_ALLOWED_IPS"),
+		TrustedProxies: e.list("MAINTENANCE_TRUSTED_PROXIES"),
+		Secret:         e.str("MAINTENANCE_SECRET", ""),
+	}
+	// the bypass cookie is an hmac keyed with KEY, which anyone could forge with an empty key
+	if cfg.Maintenance.Secret != "" && cfg.EncryptionKey == "" {
+		e.problem("MAINTENANCE_SECRET", "requires KEY to be set")
+	}
+	for _, ip := range cfg.Maintenance.AllowedIPs {
+		if !validIPOrCIDR(ip) {
+			e.problem("MAINTENANCE_ALLOWED_IPS", fmt.Sprintf("%q is not an ip address or cidr range", ip))
+		}
+	}
+	for _, ip := range cfg.Maintenance.TrustedProxies {
+		if !validIPOrCIDR(ip) {
+			e.problem("MAINTENANCE_TRUSTED_PROXIES", fmt.Sprintf("%q is not an ip address or cidr range", ip))
+		}
+	}
+
+	defaultLevel := "info"
+	if cfg.Debug {
+		defaultLevel = "debug"
+	}
+
+	cfg.Log = LogConfig{
+		Level:   e.oneOf("LOG_LEVEL", defaultLevel, "debug", "info", "warn", "error"),
+		Format:  e.oneOf("LOG_FORMAT", "text", "text", "json"),
+		File:    e.str("LOG_FILE", ""),
+		MaxSize: e.int("LOG_MAX_SIZE", 100),
+		MaxAge:  e.int("LOG_MAX_AGE", 7),
+	}
+
+	cfg.Health = HealthConfig{
+		Enabled: e.bool("HEALTH_ENDPOINTS", false),
+		Timeout: time.Duration(e.int("HEALTH_TIMEOUT", 2)) * time.Second,
+	}
+
+	cfg.Metrics = MetricsConfig{
+		Enabled: e.bool("METRICS_ENABLED", false),
+		Path:    e.str("METRICS_PATH", "/metrics"),
+		Port:    e.port("METRICS_PORT", ""),
+		Token:   e.str("METRICS_TOKEN", ""),
+	}
+	if !strings.HasPrefix(cfg.Metrics.Path, "/") {
+		e.problem("METRICS_PATH", "must start with /")
+	}
+
+	cfg.TLS = TLSConfig{
+		CertFile:              e.str("TLS_CERT_FILE", ""),
+		KeyFile:               e.str("TLS_KEY_FILE", ""),
+		RedirectPort:          e.port("TLS_REDIRECT_PORT", ""),
+		HSTSMaxAge:            e.int("HSTS_MAX_AGE", 31536000),
+		HSTSIncludeSubdomains: e.bool("HSTS_INCLUDE_SUBDOMAINS", false),
+	}
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		e.required("TLS_CERT_FILE", "TLS_KEY_FILE")
+	}
+	// the redirect needs a host to send clients to, and the request's Host header can't be trusted
+	if cfg.TLS.RedirectPort != "" {
+		e.required("SERVER_NAME")
+	}
+
+	cfg.Mail = MailConfig{
+		Domain:      e.str("MAIL_DOMAIN", ""),
+		Host:        e.str("SMTP_HOST", ""),
+		Port:        e.int("SMTP_PORT", 1025),
+		Username:    e.str("SMTP_USERNAME", ""),
+		Password:    e.str("SMTP_PASSWORD", ""),
+		Encryption:  e.oneOf("SMTP_ENCRYPTION", "", "", "tls", "ssl", "none"),
+		FromName:    e.str("FROM_NAME", ""),
+		FromAddress: e.str("FROM_ADDRESS", ""),
+		API:         e.oneOf("MAILER_API", "", "", "smtp", "mailgun", "sparkpost", "sendgrid"),
+		APIKey:      e.str("MAILER_KEY", ""),
+		APIURL:      e.str("MAILER_URL", ""),
+	}
+
+	cfg.S3.Key = e.str("S3_KEY", "")
+	cfg.S3.Secret = e.str("S3_SECRET", "")
+	cfg.S3.Region = e.str("S3_REGION", "")
+	cfg.S3.Endpoint = e.str("S3_ENDPOINT", "")
+	cfg.S3.Bucket = e.str("S3_BUCKET", "")
+
+	cfg.Minio.Endpoint = e.str("MINIO_ENDPOINT", "")
+	cfg.Minio.Key = e.str("MINIO_KEY", "")
+	cfg.Minio.Secret = e.str("MINIO_SECRET", "")
+	cfg.Minio.UseSSL = e.bool("MINIO_USESSL", false)
+	cfg.Minio.Region = e.str("MINIO_REGION", "")
+	cfg.Minio.Bucket = e.str("MINIO_BUCKET", "")
+
+	cfg.SFTP.Host = e.str("SFTP_HOST", "")
+	cfg.SFTP.User = e.str("SFTP_USER", "")
+	cfg.SFTP.Pass = e.str("SFTP_PASS", "")
+	cfg.SFTP.Port = e.port("SFTP_PORT", "")
+
+	cfg.WebDAV.Host = e.str("WEBDAV_HOST", "")
+	cfg.WebDAV.User = e.str("WEBDAV_USER", "")
+	cfg.WebDAV.Pass = e.str("WEBDAV_PASS", "")
+
+	if len(e.errs) > 0 {
+		return nil, e.errs
+	}
+
+	return &cfg, nil
+}
+
+// envReader reads typed values from the environment, collecting every problem it finds
+// rather than stopping at the first one
+type envReader struct {
+	getenv func(string) string
+	errs   ConfigError
+}
+
+func (e *envReader) problem(key, message string) {
+	e.errs = append(e.errs, ConfigProblem{Key: key, Message: message})
+}
+
+func (e *envReader) str(key, def string) string {
+	if v := strings.TrimSpace(e.getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// list splits a comma separated value, dropping empty entries
+func (e *envReader) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(e.str(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (e *envReader) required(keys ...string) {
+	for _, key := range keys {
+		if strings.TrimSpace(e.getenv(key)) == "" {
+			e.problem(key, "is required")
+		}
+	}
+}
+
+func (e *envReader) int(key string, def int) int {
+	v := e.str(key, "")
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		e.problem(key, fmt.Sprintf("%q is not a whole number", v))
+		return def
+	}
+	return i
+}
+
+func (e *envReader) bool(key string, def bool) bool {
+	v := e.str(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.problem(key, fmt.Sprintf("%q is not true or false", v))
+		return def
+	}
+	return b
+}
+
+func (e *envReader) port(key, def string) string {
+	v := e.str(key, def)
+	if v == "" {
+		return v
+	}
+	if p, err := strconv.Atoi(v); err != nil || p < 1 || p > 65535 {
+		e.problem(key, fmt.Sprintf("%q is not a valid port", v))
+		return def
+	}
+	return v
+}
+
+func (e *envReader) oneOf(key, def string, allowed ...string) string {
+	v := strings.ToLower(e.str(key, def))
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	var choices []string
+	for _, a := range allowed {
+		if a != "" {
+			choices = append(choices, a)
+		}
+	}
+	e.problem(key, fmt.Sprintf("%q must be one of %s", v, strings.Join(choices, ", ")))
+	return def
+}
+
+// database reads the settings for one database connection from the keys starting with prefix.
+// Migrations are read from the migrations folder, unless <prefix>MIGRATIONS names another.
+func (e *envReader) database(prefix, migrations string) DatabaseConfig {
+	db := DatabaseConfig{
+		Type:     e.oneOf(prefix+"TYPE", "", "", "postgres", "postgresql", "mysql", "mariadb", "sqlite"),
+		Host:     e.str(prefix+"HOST", ""),
+		Port:     e.port(prefix+"PORT", ""),
+		User:     e.str(prefix+"USER", ""),
+		Password: e.str(prefix+"PASS", ""),
+		Name:     e.str(prefix+"NAME", ""),
+		SSLMode:  e.str(prefix+"SSL_MODE", "disable"),
+		Charset:  e.str(prefix+"CHARSET", "utf8mb4"),
+
+		MaxOpenConns:    e.int(prefix+"MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    e.int(prefix+"MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime: time.Duration(e.int(prefix+"CONN_MAX_LIFETIME", 300)) * time.Second,
+
+		Replicas:             e.list(prefix + "REPLICAS"),
+		ReplicaCheckInterval: time.Duration(e.int(prefix+"REPLICA_CHECK_INTERVAL", 10)) * time.Second,
+
+		AutoMigrate:      e.bool(prefix+"AUTO_MIGRATE", false),
+		AllowDestructive: e.bool(prefix+"ALLOW_DESTRUCTIVE", false),
+		Migrations:       e.str(prefix+"MIGRATIONS", migrations),
+	}
+
+	switch db.Type {
+	case "":
+	case "sqlite":
+		// sqlite only needs a file, which is relative to the application root unless absolute
+		if db.Name == "" {
+			db.Name = "database.sqlite"
+		}
+		if len(db.Replicas) > 0 {
+			e.problem(prefix+"REPLICAS", "read replicas are not supported for sqlite")
+		}
+	default:
+		e.required(prefix+"HOST", prefix+"USER", prefix+"NAME")
+	}
+	return db
+}
+
+func validDatabaseName(name string) bool {
+	for _, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
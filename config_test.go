@@ -0,0 +1,173 @@
+package navitas
+
+import (
+	"errors"
+	"testing"
+)
+
+func envFrom(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal("unexpected error loading empty config:", err)
+	}
+
+	if cfg.Port != "4000" {
+		t.Error("wrong default port; expected 4000 and got", cfg.Port)
+	}
+
+	if cfg.Uploads.MaxUploadSize != 10<<20 {
+		t.Error("wrong default max upload size:", cfg.Uploads.MaxUploadSize)
+	}
+
+	if cfg.Cookie.Lifetime != 60 {
+		t.Error("wrong default cookie lifetime:", cfg.Cookie.Lifetime)
+	}
+
+	if !cfg.Secure {
+		t.Error("secure should default to true")
+	}
+
+	if cfg.Cookie.Domain != "" {
+		t.Error("cookie domain should default to empty, for a host only cookie; got", cfg.Cookie.Domain)
+	}
+
+	if cfg.Cache != "memory" {
+		t.Error("wrong default cache; expected memory and got", cfg.Cache)
+	}
+}
+
+func TestLoadConfig_AggregatesErrors(t *testing.T) {
+	_, err := loadConfig(envFrom(map[string]string{
+		"MAX_UPLOAD_SIZE": "ten megs",
+		"COOKIE_LIFETIME": "forever",
+		"SMTP_PORT":       "abc",
+		"DATABASE_TYPE":   "postgres",
+		"CACHE":           "redis",
+	}))
+	if err == nil {
+		t.Fatal("expected an error for invalid config")
+	}
+
+	var cfgErr ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a ConfigError, got %T", err)
+	}
+
+	expected := map[string]bool{
+		"MAX_UPLOAD_SIZE": false,
+		"COOKIE_LIFETIME": false,
+		"SMTP_PORT":       false,
+		"DATABASE_HOST":   false,
+		"DATABASE_USER":   false,
+		"DATABASE_NAME":   false,
+		"REDIS_HOST":      false,
+	}
+
+	for _, p := range cfgErr {
+		expected[p.Key] = true
+	}
+
+	for key, found := range expected {
+		if !found {
+			t.Error("expected a problem to be reported for", key)
+		}
+	}
+}
+
+func TestLoadConfig_RedirectRequiresServerName(t *testing.T) {
+	_, err := loadConfig(envFrom(map[string]string{
+		"TLS_REDIRECT_PORT": "80",
+	}))
+
+	var cfgErr ConfigError
+	if !errors.As(err, &cfgErr) || len(cfgErr) != 1 || cfgErr[0].Key != "SERVER_NAME" {
+		t.Fatal("expected SERVER_NAME to be required with TLS_REDIRECT_PORT, got", err)
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"TLS_REDIRECT_PORT": "80",
+		"SERVER_NAME":       "example.com",
+	}))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if cfg.TLS.RedirectPort != "80" || cfg.ServerName != "example.com" {
+		t.Errorf("unexpected tls redirect settings: %q on %q", cfg.TLS.RedirectPort, cfg.ServerName)
+	}
+}
+
+func TestLoadConfig_NamedDatabases(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"DATABASES":             "reporting, Archive",
+		"DB_REPORTING_TYPE":     "postgres",
+		"DB_REPORTING_HOST":     "warehouse",
+		"DB_REPORTING_USER":     "reader",
+		"DB_REPORTING_NAME":     "reports",
+		"DB_ARCHIVE_TYPE":       "sqlite",
+		"DB_ARCHIVE_MIGRATIONS": "db/archive",
+	}))
+	if err != nil {
+		t.Fatal("unexpected error loading named databases:", err)
+	}
+
+	reporting := cfg.Databases["reporting"]
+	if reporting.Host != "warehouse" || reporting.Name != "reports" || reporting.Migrations != "migrations/reporting" {
+		t.Errorf("unexpected reporting database config: %+v", reporting)
+	}
+
+	archive := cfg.Databases["archive"]
+	if archive.Name != "database.sqlite" || archive.Migrations != "db/archive" {
+		t.Errorf("unexpected archive database config: %+v", archive)
+	}
+
+	if cfg.Database.Migrations != "migrations" {
+		t.Error("expected the default database to use the migrations folder, got", cfg.Database.Migrations)
+	}
+
+	_, err = loadConfig(envFrom(map[string]string{
+		"DATABASES":         "reporting,bad-name",
+		"DB_REPORTING_TYPE": "mysql",
+	}))
+
+	var cfgErr ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a ConfigError, got %v", err)
+	}
+	reported := map[string]bool{}
+	for _, p := range cfgErr {
+		reported[p.Key] = true
+	}
+	for _, key := range []string{"DATABASES", "DB_REPORTING_HOST", "DB_REPORTING_USER", "DB_REPORTING_NAME"} {
+		if !reported[key] {
+			t.Error("expected a problem to be reported for", key)
+		}
+	}
+}
+
+func TestLoadConfig_MaintenanceSecretRequiresKey(t *testing.T) {
+	_, err := loadConfig(envFrom(map[string]string{
+		"MAINTENANCE_SECRET": "let-me-in",
+	}))
+
+	var cfgErr ConfigError
+	if !errors.As(err, &cfgErr) || len(cfgErr) != 1 || cfgErr[0].Key != "MAINTENANCE_SECRET" {
+		t.Fatal("expected KEY to be required with MAINTENANCE_SECRET, got", err)
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"MAINTENANCE_SECRET": "let-me-in",
+		"KEY":                "0123456789abcdef0123456789abcdef",
+	}))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if cfg.Maintenance.Secret != "let-me-in" {
+		t.Error("expected the maintenance secret to be loaded, got", cfg.Maintenance.Secret)
+	}
+}
@@ -0,0 +1,532 @@
+// Package data provides a small generic repository for reading and writing models with
+// database/sql, on top of the application's navitas.Database. It works with postgres, mysql,
+// mariadb and sqlite.
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmozi/navitas"
+)
+
+// ErrNotFound is returned when no record matches
+var ErrNotFound = errors.New("data: record not found")
+
+// Model is implemented by the structs a Repository stores. Fields are mapped to columns with a
+// `db:"column"` tag; fields without a tag, or tagged "-", are ignored. The primary key is the
+// column named id, and `db:"id,omitempty"` leaves it out of inserts so the database assigns it.
+// time.Time fields mapped to created_at and updated_at are set automatically.
+type Model interface {
+	Table() string
+}
+
+// Cond filters records. Each key is a column name, optionally followed by an operator, such as
+// "email", "expiry >" or "id in". The operator defaults to =, and a nil value compares with IS NULL.
+// All conditions must match.
+type Cond map[string]any
+
+// Page is one page of results from Paginate
+type Page[T any] struct {
+	Items    []*T
+	Page     int
+	PerPage  int
+	Total    int
+	LastPage int
+}
+
+// Repository reads and writes models of type T on the primary database. If the context carries a
+// transaction, every query joins it. WithReplica returns a repository that reads from a replica.
+type Repository[T any] struct {
+	db      navitas.Database
+	table   string
+	meta    *modelMeta
+	dialect dialect
+	replica bool
+}
+
+// NewRepository returns a repository for T, stored in the table named by T's Table method
+func NewRepository[T any, PT interface {
+	*T
+	Model
+}](db navitas.Database) *Repository[T] {
+	var model PT = new(T)
+	return &Repository[T]{
+		db:      db,
+		table:   model.Table(),
+		meta:    metaFor(reflect.TypeOf(model).Elem()),
+		dialect: dialectFor(db.DatabaseType),
+	}
+}
+
+// WithReplica returns a copy of the repository that reads from a read replica when one is
+// available. Replicas can lag behind the primary, so a record written a moment ago may not be
+// found yet; only use it for reads that can tolerate that.
+func (r *Repository[T]) WithReplica() *Repository[T] {
+	replica := *r
+	replica.replica = true
+	return &replica
+}
+
+// Get returns the record with the given primary key, or ErrNotFound
+func (r *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	return r.First(ctx, Cond{r.meta.pk.name: id})
+}
+
+// First returns the first record matching cond in the given order, or ErrNotFound. Each orderBy
+// entry is a column name, optionally followed by asc or desc.
+func (r *Repository[T]) First(ctx context.Context, cond Cond, orderBy ...string) (*T, error) {
+	items, err := r.find(ctx, cond, orderBy, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNotFound
+	}
+	return items[0], nil
+}
+
+// GetAll returns every record matching cond in the given order
+func (r *Repository[T]) GetAll(ctx context.Context, cond Cond, orderBy ...string) ([]*T, error) {
+	return r.find(ctx, cond, orderBy, 0, 0)
+}
+
+// Count returns the number of records matching cond
+func (r *Repository[T]) Count(ctx context.Context, cond Cond) (int, error) {
+	q := r.newQuery()
+	q.WriteString("SELECT COUNT(*) FROM " + r.dialect.ident(r.table))
+	if err := q.where(cond); err != nil {
+		return 0, err
+	}
+
+	db, err := r.reader(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = db.QueryRowContext(ctx, q.String(), q.args...).Scan(&count)
+	return count, err
+}
+
+// Paginate returns one page of the records matching cond. Pages are numbered from 1.
+func (r *Repository[T]) Paginate(ctx context.Context, cond Cond, page, perPage int, orderBy ...string) (*Page[T], error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	total, err := r.Count(ctx, cond)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.find(ctx, cond, orderBy, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[T]{
+		Items:    items,
+		Page:     page,
+		PerPage:  perPage,
+		Total:    total,
+		LastPage: max(1, (total+perPage-1)/perPage),
+	}, nil
+}
+
+// Insert adds m to the table, sets its timestamps and primary key, and returns the new id
+func (r *Repository[T]) Insert(ctx context.Context, m *T) (int64, error) {
+	v := reflect.ValueOf(m).Elem()
+	now := time.Now()
+	r.meta.setTime(v, r.meta.createdAt, now, true)
+	r.meta.setTime(v, r.meta.updatedAt, now, true)
+
+	var cols, values []string
+	q := r.newQuery()
+	for _, c := range r.meta.columns {
+		f := v.FieldByIndex(c.index)
+		if c.omitEmpty && f.IsZero() {
+			continue
+		}
+		cols = append(cols, r.dialect.ident(c.name))
+		values = append(values, q.arg(f.Interface()))
+	}
+
+	q.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.dialect.ident(r.table), strings.Join(cols, ", "), strings.Join(values, ", ")))
+
+	db, err := r.writer(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	if r.dialect.returning {
+		q.WriteString(" RETURNING " + r.dialect.ident(r.meta.pk.name))
+		err = db.QueryRowContext(ctx, q.String(), q.args...).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		res, err := db.ExecContext(ctx, q.String(), q.args...)
+		if err != nil {
+			return 0, err
+		}
+		id, err = res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pk := v.FieldByIndex(r.meta.pk.index)
+	switch {
+	case pk.CanInt():
+		pk.SetInt(id)
+	case pk.CanUint():
+		pk.SetUint(uint64(id))
+	}
+
+	return id, nil
+}
+
+// Update saves every column of m, matched by its primary key, and sets its updated_at timestamp
+func (r *Repository[T]) Update(ctx context.Context, m *T) error {
+	v := reflect.ValueOf(m).Elem()
+	r.meta.setTime(v, r.meta.updatedAt, time.Now(), false)
+
+	var sets []string
+	q := r.newQuery()
+	for _, c := range r.meta.columns {
+		if c.name == r.meta.pk.name {
+			continue
+		}
+		sets = append(sets, r.dialect.ident(c.name)+" = "+q.arg(v.FieldByIndex(c.index).Interface()))
+	}
+
+	q.WriteString(fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s",
+		r.dialect.ident(r.table),
+		strings.Join(sets, ", "),
+		r.dialect.ident(r.meta.pk.name),
+		q.arg(v.FieldByIndex(r.meta.pk.index).Interface())))
+
+	db, err := r.writer(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, q.String(), q.args...)
+	return err
+}
+
+// Delete removes the record with the given primary key
+func (r *Repository[T]) Delete(ctx context.Context, id any) error {
+	_, err := r.DeleteWhere(ctx, Cond{r.meta.pk.name: id})
+	return err
+}
+
+// DeleteWhere removes every record matching cond, and returns how many were removed. An empty
+// condition is refused, rather than emptying the table.
+func (r *Repository[T]) DeleteWhere(ctx context.Context, cond Cond) (int64, error) {
+	if len(cond) == 0 {
+		return 0, errors.New("data: refusing to delete without a condition")
+	}
+
+	q := r.newQuery()
+	q.WriteString("DELETE FROM " + r.dialect.ident(r.table))
+	if err := q.where(cond); err != nil {
+		return 0, err
+	}
+
+	db, err := r.writer(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.ExecContext(ctx, q.String(), q.args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repository[T]) find(ctx context.Context, cond Cond, orderBy []string, limit, offset int) ([]*T, error) {
+	cols := make([]string, len(r.meta.columns))
+	for i, c := range r.meta.columns {
+		cols[i] = r.dialect.ident(c.name)
+	}
+
+	q := r.newQuery()
+	q.WriteString(fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), r.dialect.ident(r.table)))
+	if err := q.where(cond); err != nil {
+		return nil, err
+	}
+	if err := q.orderBy(orderBy); err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		q.WriteString(fmt.Sprintf(" LIMIT %d", limit))
+	}
+	if offset > 0 {
+		q.WriteString(fmt.Sprintf(" OFFSET %d", offset))
+	}
+
+	db, err := r.reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, q.String(), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*T
+	dest := make([]any, len(r.meta.columns))
+	for rows.Next() {
+		item := new(T)
+		v := reflect.ValueOf(item).Elem()
+		for i, c := range r.meta.columns {
+			dest[i] = v.FieldByIndex(c.index).Addr().Interface()
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// reader returns the transaction carried by ctx, or the pool reads go to: the primary, or a
+// replica for a repository from WithReplica
+func (r *Repository[T]) reader(ctx context.Context) (navitas.Querier, error) {
+	if tx, ok := navitas.TxFromContext(ctx); ok {
+		return tx, nil
+	}
+	if r.db.Pool == nil {
+		return nil, errors.New("data: no database connection")
+	}
+	if r.replica {
+		return r.db.Reader(), nil
+	}
+	return r.db.Primary(), nil
+}
+
+// writer returns the transaction carried by ctx, or the primary pool
+func (r *Repository[T]) writer(ctx context.Context) (navitas.Querier, error) {
+	if _, ok := navitas.TxFromContext(ctx); !ok && r.db.Pool == nil {
+		return nil, errors.New("data: no database connection")
+	}
+	return r.db.Querier(ctx), nil
+}
+
+func (r *Repository[T]) newQuery() *query {
+	return &query{dialect: r.dialect, meta: r.meta}
+}
+
+// column maps one struct field onto a table column
+type column struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// modelMeta is the column mapping for a model type, which is worked out once per type
+type modelMeta struct {
+	columns   []column
+	byName    map[string]column
+	pk        column
+	createdAt *column
+	updatedAt *column
+}
+
+var metaCache sync.Map // reflect.Type -> *modelMeta
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func metaFor(t reflect.Type) *modelMeta {
+	if meta, ok := metaCache.Load(t); ok {
+		return meta.(*modelMeta)
+	}
+
+	meta := &modelMeta{byName: make(map[string]column), pk: column{name: "id"}}
+	for _, f := range reflect.VisibleFields(t) {
+		tag, ok := f.Tag.Lookup("db")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		c := column{name: name, index: f.Index, omitEmpty: opts == "omitempty"}
+		meta.columns = append(meta.columns, c)
+		meta.byName[name] = c
+
+		switch {
+		case name == "id":
+			meta.pk = c
+		case name == "created_at" && f.Type == timeType:
+			meta.createdAt = &c
+		case name == "updated_at" && f.Type == timeType:
+			meta.updatedAt = &c
+		}
+	}
+
+	actual, _ := metaCache.LoadOrStore(t, meta)
+	return actual.(*modelMeta)
+}
+
+// setTime sets a timestamp field to now. When onlyIfZero is true, a value set by the caller is kept.
+func (m *modelMeta) setTime(v reflect.Value, c *column, now time.Time, onlyIfZero bool) {
+	if c == nil {
+		return
+	}
+	f := v.FieldByIndex(c.index)
+	if onlyIfZero && !f.IsZero() {
+		return
+	}
+	f.Set(reflect.ValueOf(now))
+}
+
+// dialect covers the sql differences between the supported databases
+type dialect struct {
+	numbered  bool // placeholders are $1, $2 rather than ?
+	returning bool // inserts return the new id with RETURNING
+	quote     string
+}
+
+func dialectFor(dbType string) dialect {
+	switch dbType {
+	case "postgres", "postgresql", "pgx":
+		return dialect{numbered: true, returning: true, quote: `"`}
+	case "mysql", "mariadb":
+		return dialect{quote: "`"}
+	}
+	return dialect{quote: `"`}
+}
+
+func (d dialect) ident(name string) string {
+	return d.quote + strings.ReplaceAll(name, d.quote, d.quote+d.quote) + d.quote
+}
+
+func (d dialect) placeholder(n int) string {
+	if d.numbered {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// operators are the comparisons allowed in a Cond key
+var operators = map[string]string{
+	"=": "=", "!=": "<>", "<>": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">=",
+	"like": "LIKE", "not like": "NOT LIKE", "in": "IN", "not in": "NOT IN",
+}
+
+// query builds a statement and its arguments
+type query struct {
+	strings.Builder
+	dialect dialect
+	meta    *modelMeta
+	args    []any
+}
+
+func (q *query) arg(v any) string {
+	q.args = append(q.args, v)
+	return q.dialect.placeholder(len(q.args))
+}
+
+func (q *query) where(cond Cond) error {
+	if len(cond) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(cond))
+	for k := range cond {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	clauses := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, op, _ := strings.Cut(strings.TrimSpace(key), " ")
+		op = strings.ToLower(strings.TrimSpace(op))
+		if op == "" {
+			op = "="
+		}
+
+		if _, ok := q.meta.byName[name]; !ok {
+			return fmt.Errorf("data: unknown column %q", name)
+		}
+		sqlOp, ok := operators[op]
+		if !ok {
+			return fmt.Errorf("data: unknown operator %q", op)
+		}
+
+		col := q.dialect.ident(name)
+		value := cond[key]
+
+		switch {
+		case value == nil && sqlOp == "=":
+			clauses = append(clauses, col+" IS NULL")
+		case value == nil && sqlOp == "<>":
+			clauses = append(clauses, col+" IS NOT NULL")
+		case sqlOp == "IN" || sqlOp == "NOT IN":
+			list := reflect.ValueOf(value)
+			if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+				return fmt.Errorf("data: %q needs a slice", key)
+			}
+			if list.Len() == 0 {
+				if sqlOp == "IN" {
+					clauses = append(clauses, "1 = 0")
+				}
+				continue
+			}
+			placeholders := make([]string, list.Len())
+			for i := range placeholders {
+				placeholders[i] = q.arg(list.Index(i).Interface())
+			}
+			clauses = append(clauses, fmt.Sprintf("%s %s (%s)", col, sqlOp, strings.Join(placeholders, ", ")))
+		default:
+			clauses = append(clauses, fmt.Sprintf("%s %s %s", col, sqlOp, q.arg(value)))
+		}
+	}
+
+	if len(clauses) > 0 {
+		q.WriteString(" WHERE " + strings.Join(clauses, " AND "))
+	}
+	return nil
+}
+
+func (q *query) orderBy(orderBy []string) error {
+	if len(orderBy) == 0 {
+		return nil
+	}
+
+	terms := make([]string, 0, len(orderBy))
+	for _, o := range orderBy {
+		name, dir, _ := strings.Cut(strings.TrimSpace(o), " ")
+		if _, ok := q.meta.byName[name]; !ok {
+			return fmt.Errorf("data: unknown column %q", name)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(dir)) {
+		case "", "asc":
+			terms = append(terms, q.dialect.ident(name)+" ASC")
+		case "desc":
+			terms = append(terms, q.dialect.ident(name)+" DESC")
+		default:
+			return fmt.Errorf("data: unknown sort direction %q", dir)
+		}
+	}
+
+	q.WriteString(" ORDER BY " + strings.Join(terms, ", "))
+	return nil
+}
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmozi/navitas"
+)
+
+type widget struct {
+	ID        int       `db:"id,omitempty"`
+	Name      string    `db:"name"`
+	Size      int       `db:"size"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Note      string    `db:"-"`
+}
+
+func (w *widget) Table() string {
+	return "widgets"
+}
+
+func newWidgetRepo(t *testing.T) *Repository[widget] {
+	t.Helper()
+
+	pool, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	_, err = pool.Exec(`create table widgets (
+		id integer primary key autoincrement,
+		name text not null,
+		size integer not null,
+		created_at datetime not null,
+		updated_at datetime not null
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewRepository[widget](navitas.Database{DatabaseType: "sqlite", Pool: pool})
+}
+
+func TestRepository(t *testing.T) {
+	repo := newWidgetRepo(t)
+	ctx := context.Background()
+
+	for i, name := range []string{"bolt", "nut", "gear"} {
+		w := &widget{Name: name, Size: i + 1}
+		id, err := repo.Insert(ctx, w)
+		if err != nil {
+			t.Fatal("unexpected error inserting:", err)
+		}
+		if w.ID != int(id) || w.CreatedAt.IsZero() || w.UpdatedAt.IsZero() {
+			t.Errorf("expected id and timestamps to be set, got %+v", w)
+		}
+	}
+
+	w, err := repo.Get(ctx, 2)
+	if err != nil || w.Name != "nut" {
+		t.Fatalf("expected to get nut, got %+v: %v", w, err)
+	}
+
+	w.Size = 10
+	if err := repo.Update(ctx, w); err != nil {
+		t.Fatal("unexpected error updating:", err)
+	}
+
+	big, err := repo.GetAll(ctx, Cond{"size >=": 3}, "size desc")
+	if err != nil || len(big) != 2 || big[0].Name != "nut" || big[1].Name != "gear" {
+		t.Errorf("expected nut then gear, got %+v: %v", big, err)
+	}
+
+	some, err := repo.GetAll(ctx, Cond{"name in": []string{"bolt", "gear"}}, "name")
+	if err != nil || len(some) != 2 || some[0].Name != "bolt" {
+		t.Errorf("expected bolt and gear, got %+v: %v", some, err)
+	}
+
+	page, err := repo.Paginate(ctx, nil, 2, 2, "id")
+	if err != nil || page.Total != 3 || page.LastPage != 2 || len(page.Items) != 1 || page.Items[0].Name != "gear" {
+		t.Errorf("unexpected second page: %+v: %v", page, err)
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Error("expected deleted record to be not found, got", err)
+	}
+
+	if _, err := repo.DeleteWhere(ctx, nil); err == nil {
+		t.Error("expected delete without a condition to be refused")
+	}
+
+	if _, err := repo.GetAll(ctx, Cond{"name; drop table widgets": 1}); err == nil {
+		t.Error("expected unknown column to be rejected")
+	}
+}
+
+func TestRepository_JoinsTransaction(t *testing.T) {
+	repo := newWidgetRepo(t)
+	ctx := context.Background()
+
+	err := repo.db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		txCtx := navitas.ContextWithTx(ctx, tx)
+		if _, err := repo.Insert(txCtx, &widget{Name: "bolt"}); err != nil {
+			return err
+		}
+		if n, err := repo.Count(txCtx, nil); err != nil || n != 1 {
+			t.Errorf("expected insert to be visible in the transaction, got %d: %v", n, err)
+		}
+		return errors.New("roll back")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction to fail")
+	}
+
+	if n, err := repo.Count(ctx, nil); err != nil || n != 0 {
+		t.Errorf("expected insert to be rolled back, got %d: %v", n, err)
+	}
+}
+
+func TestRepository_ReadsFromPrimary(t *testing.T) {
+	repo := newWidgetRepo(t)
+	ctx := context.Background()
+
+	db, err := repo.reader(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db != repo.db.Primary() {
+		t.Error("expected reads to go to the primary by default")
+	}
+
+	replica := repo.WithReplica()
+	if repo.replica || !replica.replica {
+		t.Error("expected WithReplica to return a copy that reads from a replica")
+	}
+
+	// the copy shares the table, so it reads what the original writes
+	if _, err := repo.Insert(ctx, &widget{Name: "bolt", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := replica.Count(ctx, nil); err != nil || n != 1 {
+		t.Errorf("expected the replica repository to read the table, got %d: %v", n, err)
+	}
+}
@@ -0,0 +1,297 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Database is the application's connection to its sql database. Pool is the primary, which takes
+// every write. When read replicas are configured, Reader spreads reads across them.
+type Database struct {
+	DatabaseType string
+	Pool         *sql.DB
+	replicas     *replicaSet
+}
+
+// Primary returns the pool for the primary database
+func (d Database) Primary() *sql.DB {
+	return d.Pool
+}
+
+// Reader returns a pool for read only queries. Healthy replicas are used in turn, and the
+// primary is returned when there are no replicas or none of them are reachable.
+func (d Database) Reader() *sql.DB {
+	if d.replicas != nil {
+		if db := d.replicas.next(); db != nil {
+			return db
+		}
+	}
+	return d.Pool
+}
+
+// HealthyReplicas returns the number of replicas currently in rotation
+func (d Database) HealthyReplicas() int {
+	if d.replicas == nil {
+		return 0
+	}
+	return d.replicas.healthy()
+}
+
+// Close stops the replica health checks and closes every pool
+func (d Database) Close() error {
+	var errs []error
+	if d.replicas != nil {
+		errs = append(errs, d.replicas.close())
+	}
+	if d.Pool != nil {
+		errs = append(errs, d.Pool.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openDatabase connects to the database described by cfg, along with its read replicas
+func (n *Navitas) openDatabase(cfg DatabaseConfig) (Database, error) {
+	cfg = n.resolveDatabase(cfg)
+
+	pool, err := n.OpenDB(cfg.Type, cfg.DSN())
+	if err != nil {
+		return Database{}, err
+	}
+	cfg.configurePool(pool)
+
+	db := Database{DatabaseType: cfg.Type, Pool: pool}
+	if len(cfg.Replicas) > 0 {
+		db.replicas, err = n.openReplicas(cfg)
+		if err != nil {
+			_ = pool.Close()
+			return Database{}, err
+		}
+	}
+	return db, nil
+}
+
+// openNamedDatabases connects to each named database that was not supplied with WithNamedDB
+func (n *Navitas) openNamedDatabases() error {
+	for _, name := range n.databaseNames() {
+		if _, ok := n.DBs[name]; ok {
+			continue
+		}
+
+		db, err := n.openDatabase(n.Config.Databases[name])
+		if err != nil {
+			return fmt.Errorf("database %s: %w", name, err)
+		}
+		if n.DBs == nil {
+			n.DBs = make(map[string]Database)
+		}
+		n.DBs[name] = db
+	}
+	return nil
+}
+
+// databaseNames returns the names of the configured named databases in order
+func (n *Navitas) databaseNames() []string {
+	names := make([]string, 0, len(n.Config.Databases))
+	for name := range n.Config.Databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Connection returns a Navitas that uses the named database in place of the default one, for
+// running migrations, schema dumps and seeders against it. It shares the application's root path,
+// config and loggers, and reads migrations from the named database's own folder, along with the
+// Go migrations registered for it with WithNamedGoMigrations.
+func (n *Navitas) Connection(name string) (*Navitas, error) {
+	cfg, ok := n.Config.Databases[name]
+	if !ok {
+		return nil, fmt.Errorf("no database named %q", name)
+	}
+
+	conn := &Navitas{
+		AppName:      n.AppName,
+		Environment:  n.Environment,
+		Debug:        n.Debug,
+		Version:      n.Version,
+		ErrorLog:     n.ErrorLog,
+		InfoLog:      n.InfoLog,
+		Logger:       n.Logger,
+		RootPath:     n.RootPath,
+		Config:       n.Config,
+		DB:           n.DBs[name],
+		DBs:          n.DBs,
+		migrationsFS: n.dbMigrationsFS[name],
+
+		destructiveOK: n.destructiveOK,
+	}
+	conn.Config.Database = cfg
+	for _, m := range n.dbGoMigrations[name] {
+		conn.AddGoMigration(m)
+	}
+	return conn, nil
+}
+
+// configurePool applies the pool size and lifetime settings to db
+func (c DatabaseConfig) configurePool(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+}
+
+// replica is one read replica, which is taken out of rotation while its pings fail
+type replica struct {
+	host   string
+	pool   *sql.DB
+	online atomic.Bool
+}
+
+// replicaPingTimeout is how long a health check waits for a replica to answer
+const replicaPingTimeout = 2 * time.Second
+
+// replicaSet round robins reads across replicas, and pings each one on an interval
+type replicaSet struct {
+	replicas []*replica
+	counter  atomic.Uint64
+	logger   *slog.Logger
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// openReplicas opens a pool for each configured replica. Replicas are checked in the background,
+// so an unreachable replica does not hold up startup; until a check succeeds a replica stays out
+// of rotation and reads go to the primary.
+func (n *Navitas) openReplicas(cfg DatabaseConfig) (*replicaSet, error) {
+	set := &replicaSet{
+		logger: n.Logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	for _, host := range cfg.Replicas {
+		rcfg := cfg
+		rcfg.Host = host
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			rcfg.Host, rcfg.Port = h, p
+		}
+
+		pool, err := sql.Open(driverName(cfg.Type), rcfg.DSN())
+		if err != nil {
+			_ = set.closePools()
+			return nil, fmt.Errorf("replica %s: %w", host, err)
+		}
+		cfg.configurePool(pool)
+
+		set.replicas = append(set.replicas, &replica{host: host, pool: pool})
+	}
+
+	interval := cfg.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go set.watch(interval)
+
+	return set, nil
+}
+
+// next returns the next healthy replica, or nil if there are none
+func (s *replicaSet) next() *sql.DB {
+	count := uint64(len(s.replicas))
+	start := s.counter.Add(1)
+	for i := uint64(0); i < count; i++ {
+		r := s.replicas[(start+i)%count]
+		if r.online.Load() {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+func (s *replicaSet) healthy() int {
+	var count int
+	for _, r := range s.replicas {
+		if r.online.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+// watch checks the replicas straight away, and then every interval until the set is closed
+func (s *replicaSet) watch(interval time.Duration) {
+	defer close(s.done)
+
+	timeout := min(interval, replicaPingTimeout)
+	s.check(timeout)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.check(timeout)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// check pings every replica and moves it in or out of rotation
+func (s *replicaSet) check(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			err := r.pool.PingContext(ctx)
+			was := r.online.Swap(err == nil)
+
+			switch {
+			case err != nil && was:
+				s.logger.Warn("database replica removed from rotation", "replica", r.host, "error", err)
+			case err != nil:
+				s.logger.Debug("database replica unavailable", "replica", r.host, "error", err)
+			case err == nil && !was:
+				s.logger.Info("database replica added to rotation", "replica", r.host)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (s *replicaSet) close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+	return s.closePools()
+}
+
+func (s *replicaSet) closePools() error {
+	var errs []error
+	for _, r := range s.replicas {
+		if err := r.pool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", r.host, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func openTestSQLite(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestDatabase_Reader(t *testing.T) {
+	primary := openTestSQLite(t, "primary.db")
+	r1 := openTestSQLite(t, "r1.db")
+	r2 := openTestSQLite(t, "r2.db")
+
+	set := &replicaSet{
+		replicas: []*replica{{host: "r1", pool: r1}, {host: "r2", pool: r2}},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	set.check(time.Second)
+
+	db := Database{Pool: primary, replicas: set}
+
+	if db.Primary() != primary {
+		t.Error("expected primary to be the main pool")
+	}
+
+	seen := map[*sql.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[db.Reader()]++
+	}
+	if seen[r1] != 2 || seen[r2] != 2 {
+		t.Error("expected reads to alternate between replicas, got", seen)
+	}
+
+	_ = r1.Close()
+	set.check(time.Second)
+
+	if db.HealthyReplicas() != 1 {
+		t.Error("expected failed replica to be removed from rotation")
+	}
+	for i := 0; i < 3; i++ {
+		if db.Reader() != r2 {
+			t.Error("expected reads to go to the healthy replica")
+		}
+	}
+
+	_ = r2.Close()
+	set.check(time.Second)
+
+	if db.Reader() != primary {
+		t.Error("expected reads to fall back to the primary when no replica is healthy")
+	}
+}
+
+func TestNavitas_OpenReplicasChecksInTheBackground(t *testing.T) {
+	n := &Navitas{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cfg := DatabaseConfig{
+		Type:                 "sqlite",
+		Name:                 filepath.Join(t.TempDir(), "replica.db"),
+		Replicas:             []string{"r1"},
+		ReplicaCheckInterval: time.Hour,
+	}
+
+	set, err := n.openReplicas(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.close()
+
+	// the first check does not wait for the interval, or hold up opening the set
+	deadline := time.Now().Add(replicaPingTimeout)
+	for set.healthy() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if set.healthy() != 1 {
+		t.Error("expected the replica to be added to rotation by the first check")
+	}
+}
+
+func TestNewWithConfig_NamedDatabases(t *testing.T) {
+	reporting := fstest.MapFS{
+		"1_create_reports.up.sql":   {Data: []byte("CREATE TABLE reports (id INTEGER PRIMARY KEY);")},
+		"1_create_reports.down.sql": {Data: []byte("DROP TABLE reports;")},
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"DATABASE_TYPE":             "sqlite",
+		"DATABASES":                 "reporting",
+		"DB_REPORTING_TYPE":         "sqlite",
+		"DB_REPORTING_NAME":         "reporting.sqlite",
+		"DB_REPORTING_AUTO_MIGRATE": "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithNamedMigrations("reporting", reporting))
+	if err != nil {
+		t.Fatal("unexpected error booting with a named database:", err)
+	}
+	defer n.Shutdown(context.Background())
+
+	db, ok := n.DBs["reporting"]
+	if !ok || db.Pool == nil || db.Pool == n.DB.Pool {
+		t.Fatal("expected the reporting database to be opened separately from the default one")
+	}
+
+	var tables int
+	err = db.Pool.QueryRow("select count(*) from sqlite_master where name = 'reports'").Scan(&tables)
+	if err != nil || tables != 1 {
+		t.Error("expected the reporting migrations to run on boot:", err)
+	}
+	err = n.DB.Pool.QueryRow("select count(*) from sqlite_master where name = 'reports'").Scan(&tables)
+	if err != nil || tables != 0 {
+		t.Error("expected the default database to be left alone:", err)
+	}
+
+	conn, err := n.Connection("reporting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.MigrationsPath() != filepath.Join(n.RootPath, "migrations", "reporting") {
+		t.Error("unexpected migrations path for the reporting database:", conn.MigrationsPath())
+	}
+	status, err := conn.MigrationStatus(conn.BuildMigrationURL())
+	if err != nil || status.Version != 1 {
+		t.Errorf("expected the reporting database to be at version 1, got %+v: %v", status, err)
+	}
+
+	if _, err := n.Connection("missing"); err == nil {
+		t.Error("expected an error for an unknown database")
+	}
+}
+
+func TestNewWithConfig_NamedGoMigrations(t *testing.T) {
+	reporting := fstest.MapFS{
+		"1_create_reports.up.sql":     {Data: []byte("CREATE TABLE reports (id INTEGER PRIMARY KEY, name TEXT);")},
+		"1_create_reports.down.sql":   {Data: []byte("DROP TABLE reports;")},
+		"2_backfill_reports.go":       {Data: []byte("package migrations")},
+		"3_create_summaries.up.sql":   {Data: []byte("CREATE TABLE summaries (id INTEGER PRIMARY KEY);")},
+		"3_create_summaries.down.sql": {Data: []byte("DROP TABLE summaries;")},
+	}
+	backfill := GoMigration{
+		Version: 2,
+		Name:    "backfill_reports",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("insert into reports (name) values ('daily')")
+			return err
+		},
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"DATABASES":                 "reporting",
+		"DB_REPORTING_TYPE":         "sqlite",
+		"DB_REPORTING_NAME":         "reporting.sqlite",
+		"DB_REPORTING_AUTO_MIGRATE": "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without the Go migration, migrating would record version 2 as applied without running it
+	_, err = NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithNamedMigrations("reporting", reporting))
+	if err == nil {
+		t.Fatal("expected an error auto migrating past an unregistered Go migration")
+	}
+
+	n, err := NewWithConfig(*cfg,
+		WithRootPath(t.TempDir()),
+		WithNamedMigrations("reporting", reporting),
+		WithNamedGoMigrations("reporting", backfill),
+	)
+	if err != nil {
+		t.Fatal("unexpected error booting with a named Go migration:", err)
+	}
+	defer n.Shutdown(context.Background())
+
+	var reports int
+	err = n.DBs["reporting"].Pool.QueryRow("select count(*) from reports").Scan(&reports)
+	if err != nil || reports != 1 {
+		t.Errorf("expected the Go migration to backfill one report, got %d: %v", reports, err)
+	}
+
+	conn, err := n.Connection("reporting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := conn.MigrationStatus(conn.BuildMigrationURL())
+	if err != nil || status.Version != 3 || len(status.Migrations) != 3 {
+		t.Errorf("expected the reporting database to be at version 3 with three migrations, got %+v: %v", status, err)
+	}
+}
@@ -2,25 +2,154 @@ package navitas
 
 import (
 	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
-// OpenDB opens a connection to a sql database. dbType must be one of postgres (or pgx).
-// TODO: add support for mysql/mariadb
+// OpenDB opens a connection to a sql database. dbType must be one of postgres (or postgresql, pgx),
+// mysql, mariadb or sqlite.
 func (n *Navitas) OpenDB(dbType, dsn string) (*sql.DB, error) {
-	if dbType == "postgres" || dbType == "postgresql" {
-		dbType = "pgx"
-	}
-
-	db, err := sql.Open(dbType, dsn)
+	db, err := sql.Open(driverName(dbType), dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	err = db.Ping()
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
 	return db, nil
 
 }
+
+// driverName maps a DATABASE_TYPE onto the name its database/sql driver is registered under
+func driverName(dbType string) string {
+	switch dbType {
+	case "postgres", "postgresql":
+		return "pgx"
+	case "mysql", "mariadb":
+		return "mysql"
+	}
+	// the pure go sqlite driver registers itself as sqlite, so no cgo toolchain is needed
+	return dbType
+}
+
+// DSN builds the datasource name used to open the database with database/sql
+func (c DatabaseConfig) DSN() string {
+	var dsn string
+
+	switch c.Type {
+	case "postgres", "postgresql":
+		dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
+			c.Host,
+			c.Port,
+			c.User,
+			c.Name,
+			c.SSLMode)
+
+		// we check to see if a database passsword has been supplied, since including "password=" with nothing
+		// after it sometimes causes postgres to fail to allow a connection.
+		if c.Password != "" {
+			dsn = fmt.Sprintf("%s password=%s", dsn, c.Password)
+		}
+
+	case "mysql", "mariadb":
+		dsn = c.mysqlConfig().FormatDSN()
+
+	case "sqlite":
+		dsn = c.Name + sqlitePragmas
+
+	default:
+
+	}
+
+	return dsn
+}
+
+// MigrationURL builds the url used by the migration runner for this database
+func (c DatabaseConfig) MigrationURL() string {
+	switch c.Type {
+	case "postgres", "postgresql":
+		u := url.URL{
+			Scheme:   "postgres",
+			Host:     net.JoinHostPort(c.Host, c.Port),
+			Path:     "/" + c.Name,
+			RawQuery: url.Values{"sslmode": {c.SSLMode}}.Encode(),
+		}
+		if c.Password != "" {
+			u.User = url.UserPassword(c.User, c.Password)
+		} else {
+			u.User = url.User(c.User)
+		}
+		return u.String()
+
+	case "mysql", "mariadb":
+		// the migration driver unescapes the user and password, so they must be escaped here
+		cfg := c.mysqlConfig()
+		cfg.User = url.QueryEscape(cfg.User)
+		cfg.Passwd = url.QueryEscape(cfg.Passwd)
+		return "mysql://" + cfg.FormatDSN()
+
+	case "sqlite":
+		return "sqlite://" + c.Name + sqlitePragmas
+	}
+
+	return ""
+}
+
+// sqlitePragmas are applied to every sqlite connection. Foreign keys are off by default in sqlite,
+// and the busy timeout and write ahead log let the migration runner and the application share a file.
+const sqlitePragmas = "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// mysqlConfig converts the database settings into a go-sql-driver config. Times are parsed into
+// time.Time in UTC, and the postgres style ssl modes are mapped onto the driver's tls options.
+func (c DatabaseConfig) mysqlConfig() *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	cfg.DBName = c.Name
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.Timeout = 5 * time.Second
+
+	port := c.Port
+	if port == "" {
+		port = "3306"
+	}
+	cfg.Addr = net.JoinHostPort(c.Host, port)
+
+	charset := c.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	cfg.Params = map[string]string{"charset": charset}
+
+	cfg.TLSConfig = mysqlTLSMode(c.SSLMode)
+
+	return cfg
+}
+
+// mysqlTLSMode maps DATABASE_SSL_MODE onto a go-sql-driver tls value. The driver's own values
+// (true, false, skip-verify, preferred) are passed through unchanged.
+func mysqlTLSMode(sslMode string) string {
+	switch strings.ToLower(sslMode) {
+	case "", "disable", "false":
+		return "false"
+	case "prefer", "allow", "preferred":
+		return "preferred"
+	case "require", "skip-verify":
+		return "skip-verify"
+	case "verify-ca", "verify-full", "true":
+		return "true"
+	}
+	return sslMode
+}
@@ -0,0 +1,74 @@
+package navitas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+)
+
+// The environments an application can run in, selected with NAVITAS_ENV
+const (
+	EnvDevelopment = "development"
+	EnvTesting     = "testing"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+var environments = []string{EnvDevelopment, EnvTesting, EnvStaging, EnvProduction}
+
+// LoadEnvFiles loads the layered .env files in rootPath into the process environment, and
+// returns the active environment. Files are applied in increasing order of precedence:
+//
+//	.env
+//	.env.<environment>
+//	.env.local (not loaded in the testing environment, so tests are reproducible)
+//
+// Variables already set in the real environment always win. The environment is taken from
+// NAVITAS_ENV, which may itself be set in .env, and defaults to development.
+func LoadEnvFiles(rootPath string) (string, error) {
+	env := os.Getenv("NAVITAS_ENV")
+	if env == "" {
+		base, err := godotenv.Read(filepath.Join(rootPath, ".env"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		env = base["NAVITAS_ENV"]
+	}
+	if env == "" {
+		env = EnvDevelopment
+	}
+
+	if !inSlice(environments, env) {
+		return "", fmt.Errorf("NAVITAS_ENV %q must be one of %v", env, environments)
+	}
+
+	// godotenv never overrides a variable that is already set, so the files are loaded from
+	// highest to lowest precedence
+	files := []string{".env." + env, ".env"}
+	if env != EnvTesting {
+		files = append([]string{".env.local"}, files...)
+	}
+
+	for _, f := range files {
+		err := godotenv.Load(filepath.Join(rootPath, f))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s: %w", f, err)
+		}
+	}
+
+	// make sure the chosen environment is visible to LoadConfig, even when it was defaulted
+	err := os.Setenv("NAVITAS_ENV", env)
+	if err != nil {
+		return "", err
+	}
+
+	return env, nil
+}
+
+// IsProduction reports whether the application is running in the production environment
+func (n *Navitas) IsProduction() bool {
+	return n.Environment == EnvProduction
+}
@@ -0,0 +1,62 @@
+package navitas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".env":         "NAVITAS_ENV=staging\nNAV_TEST_BASE=base\nNAV_TEST_LAYER=base\nNAV_TEST_REAL=base\n",
+		".env.staging": "NAV_TEST_LAYER=staging\nNAV_TEST_LOCAL=staging\n",
+		".env.local":   "NAV_TEST_LOCAL=local\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := []string{"NAVITAS_ENV", "NAV_TEST_BASE", "NAV_TEST_LAYER", "NAV_TEST_LOCAL", "NAV_TEST_REAL"}
+	for _, k := range keys {
+		_ = os.Unsetenv(k)
+	}
+	t.Cleanup(func() {
+		for _, k := range keys {
+			_ = os.Unsetenv(k)
+		}
+	})
+	_ = os.Setenv("NAV_TEST_REAL", "real")
+
+	env, err := LoadEnvFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env != EnvStaging {
+		t.Error("expected staging environment from .env, got", env)
+	}
+
+	expected := map[string]string{
+		"NAV_TEST_BASE":  "base",
+		"NAV_TEST_LAYER": "staging",
+		"NAV_TEST_LOCAL": "local",
+		"NAV_TEST_REAL":  "real",
+	}
+	for k, v := range expected {
+		if got := os.Getenv(k); got != v {
+			t.Errorf("%s: expected %q and got %q", k, v, got)
+		}
+	}
+}
+
+func TestLoadEnvFiles_InvalidEnvironment(t *testing.T) {
+	t.Setenv("NAVITAS_ENV", "moon")
+
+	if _, err := LoadEnvFiles(t.TempDir()); err == nil {
+		t.Error("expected an error for an unknown environment")
+	}
+}
@@ -36,6 +36,23 @@ func (m *Minio) getCredentials() *minio.Client {
 	return client
 }
 
+// Ping checks that the bucket exists and the credentials can access it, giving up once ctx is done
+func (m *Minio) Ping(ctx context.Context) error {
+	client := m.getCredentials()
+	if client == nil {
+		return fmt.Errorf("could not create minio client for %s", m.Endpoint)
+	}
+
+	exists, err := client.BucketExists(ctx, m.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", m.Bucket)
+	}
+	return nil
+}
+
 // Put transfers a file to the remote file system
 func (m *Minio) Put(fileName, folder string) error {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -2,6 +2,7 @@ package s3filesystem
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -77,6 +78,25 @@ func (s *S3) Put(fileName, folder string) error {
 	return nil
 }
 
+// Ping checks that the bucket exists and the credentials can access it, giving up once ctx is done
+func (s *S3) Ping(ctx context.Context) error {
+	c := s.getCredentials()
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    &s.Endpoint,
+		Region:      &s.Region,
+		Credentials: c,
+	})
+	if err != nil {
+		return err
+	}
+
+	svc := s3.New(sess)
+	_, err = svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.Bucket),
+	})
+	return err
+}
+
 func (s *S3) List(prefix string) ([]filesystems.Listing, error) {
 	var listing []filesystems.Listing
 
@@ -1,18 +1,24 @@
 package sftpfilesystem
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/bmozi/navitas/filesystems"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
+// dialTimeout is how long connecting to the sftp server may take
+const dialTimeout = 10 * time.Second
+
 type SFTP struct {
 	Host string
 	User string
@@ -21,6 +27,21 @@ type SFTP struct {
 }
 
 func (s *SFTP) getCredentials() (*sftp.Client, error) {
+	_, client, err := s.dial(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := client.Getwd()
+	log.Println("Current working directory:", cwd)
+
+	return client, nil
+
+}
+
+// dial logs in to the sftp server, and returns the ssh connection along with the sftp client
+// running over it. Closing the sftp client leaves the ssh connection open. Connecting gives up
+// once ctx is done, and logging in once its deadline passes.
+func (s *SFTP) dial(ctx context.Context) (*ssh.Client, *sftp.Client, error) {
 	addr := fmt.Sprintf("%s:%s", s.Host, s.Port)
 	config := &ssh.ClientConfig{
 		User: s.User,
@@ -28,20 +49,42 @@ func (s *SFTP) getCredentials() (*sftp.Client, error) {
 			ssh.Password(s.Pass),
 		},
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         dialTimeout,
 	}
-	conn, err := ssh.Dial("tcp", addr, config)
+
+	dialer := net.Dialer{Timeout: config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	client, err := sftp.NewClient(conn)
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = netConn.SetDeadline(deadline)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
 	if err != nil {
-		return nil, err
+		_ = netConn.Close()
+		return nil, nil, err
 	}
-	cwd, err := client.Getwd()
-	log.Println("Current working directory:", cwd)
+	conn := ssh.NewClient(sshConn, chans, reqs)
 
-	return client, nil
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	_ = netConn.SetDeadline(time.Time{})
+	return conn, client, nil
+}
 
+// Ping checks that we can log in to the sftp server, giving up once ctx is done
+func (s *SFTP) Ping(ctx context.Context) error {
+	conn, client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	_ = client.Close()
+	return conn.Close()
 }
 
 func (s *SFTP) Put(fileName, folder string) error {
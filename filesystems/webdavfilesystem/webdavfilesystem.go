@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/bmozi/navitas/filesystems"
 	"github.com/studio-b12/gowebdav"
@@ -24,6 +25,16 @@ func (w *WebDAV) getCredentials() *gowebdav.Client {
 	return c
 }
 
+// pingTimeout bounds Ping, since the health check that calls it cannot cancel it
+const pingTimeout = 10 * time.Second
+
+// Ping checks that we can connect and authenticate to the webdav server
+func (w *WebDAV) Ping() error {
+	client := w.getCredentials()
+	client.SetTimeout(pingTimeout)
+	return client.Connect()
+}
+
 // Put attempts to put a file on the remote file system
 func (w *WebDAV) Put(fileName, folder string) error {
 	client := w.getCredentials()
@@ -0,0 +1,290 @@
+package navitas
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/v4/database"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	migratepostgres "github.com/golang-migrate/migrate/v4/database/postgres"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// goMigrationMarker starts the body the migration source hands out for a Go migration. The
+// database driver recognises it and calls the Go function instead of running the body as sql.
+const goMigrationMarker = "-- navitas:go-migration "
+
+// GoMigration is a migration written in Go, for changes such as data backfills that are awkward in
+// sql. It is ordered by Version alongside the sql files and recorded in the same schema_migrations
+// table. Up and Down run inside a transaction that is committed if they return nil. Down may be nil
+// if the migration cannot be reversed.
+type GoMigration struct {
+	Version uint
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// AddGoMigration registers a Go migration. Go migrations run on a connection from the application's
+// database, the one the migration runner records versions on, so they only run from within the
+// application, for example with DATABASE_AUTO_MIGRATE or the cmd/migrate program that `navitas
+// migrate` uses. An in-memory sqlite database must have a pool limited to one connection.
+func (n *Navitas) AddGoMigration(m GoMigration) {
+	if n.goMigrations == nil {
+		n.goMigrations = make(map[uint]GoMigration)
+	}
+	n.goMigrations[m.Version] = m
+}
+
+// checkGoMigrationsRegistered returns an error if the migrations folder holds a Go migration,
+// named <version>_<name>.go, that has not been registered. Migrating past it would record its
+// version as applied without ever running it.
+func (n *Navitas) checkGoMigrationsRegistered() error {
+	fsys := n.migrationsFS
+	if fsys == nil {
+		fsys = os.DirFS(n.MigrationsPath())
+	}
+
+	files, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range files {
+		prefix, _, ok := strings.Cut(strings.TrimSuffix(f, ".go"), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		if _, ok := n.goMigrations[uint(version)]; !ok {
+			errs = append(errs, fmt.Errorf("%s is not registered", f))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("go migrations must be registered with WithGoMigrations or WithNamedGoMigrations before auto migrating: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// AddNamedGoMigration registers a Go migration for the named database. Register it before New, or
+// with WithNamedGoMigrations, for DB_<NAME>_AUTO_MIGRATE to run it.
+func (n *Navitas) AddNamedGoMigration(name string, m GoMigration) {
+	if n.dbGoMigrations == nil {
+		n.dbGoMigrations = make(map[string]map[uint]GoMigration)
+	}
+	if n.dbGoMigrations[name] == nil {
+		n.dbGoMigrations[name] = make(map[uint]GoMigration)
+	}
+	n.dbGoMigrations[name][m.Version] = m
+}
+
+// goMigrationSource merges registered Go migrations into the versions of the sql migration files
+type goMigrationSource struct {
+	source.Driver
+	migrations map[uint]GoMigration
+	versions   []uint
+}
+
+func newGoMigrationSource(files source.Driver, migrations map[uint]GoMigration) (*goMigrationSource, error) {
+	s := &goMigrationSource{Driver: files, migrations: migrations}
+
+	v, err := files.First()
+	for err == nil {
+		if _, ok := migrations[v]; ok {
+			return nil, fmt.Errorf("migration version %d is used by both a sql file and a Go migration", v)
+		}
+		s.versions = append(s.versions, v)
+		v, err = files.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for v := range migrations {
+		s.versions = append(s.versions, v)
+	}
+	sort.Slice(s.versions, func(i, j int) bool { return s.versions[i] < s.versions[j] })
+
+	return s, nil
+}
+
+func (s *goMigrationSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, &fs.PathError{Op: "first", Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[0], nil
+}
+
+func (s *goMigrationSource) Prev(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if i == 0 || i == len(s.versions) || s.versions[i] != version {
+		return 0, &fs.PathError{Op: "prev", Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[i-1], nil
+}
+
+func (s *goMigrationSource) Next(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] > version })
+	if i == len(s.versions) {
+		return 0, &fs.PathError{Op: "next", Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[i], nil
+}
+
+func (s *goMigrationSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		return goMigrationBody("up", version), m.Name, nil
+	}
+	return s.Driver.ReadUp(version)
+}
+
+func (s *goMigrationSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		if m.Down == nil {
+			return nil, "", &fs.PathError{Op: "read down", Path: m.Name, Err: fs.ErrNotExist}
+		}
+		return goMigrationBody("down", version), m.Name, nil
+	}
+	return s.Driver.ReadDown(version)
+}
+
+func goMigrationBody(direction string, version uint) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("%s%s %d\n", goMigrationMarker, direction, version)))
+}
+
+// goMigrationDriver runs Go migrations on the connection the migration runner versions the
+// database with, and passes every other migration through to the real database driver
+type goMigrationDriver struct {
+	database.Driver
+	db         txBeginner // nil when the application's database is not open
+	pool       *sql.DB    // a pool opened only for migrating, closed along with the driver
+	keepOpen   bool       // the driver runs on the application's pool, which Close must not close
+	migrations map[uint]GoMigration
+}
+
+// openGoMigrationDriver opens the migration runner's database driver on a connection from the
+// application's database, so that Go migrations run on the same connection, and within the same
+// migration lock, as the version bookkeeping. Without an open database, dsn is used, and Go
+// migrations can be tracked but not run.
+func (n *Navitas) openGoMigrationDriver(dsn string) (*goMigrationDriver, error) {
+	d := &goMigrationDriver{migrations: n.goMigrations}
+	if n.DB.Pool == nil {
+		drv, err := database.Open(dsn)
+		if err != nil {
+			return nil, err
+		}
+		d.Driver = drv
+		return d, nil
+	}
+
+	ctx := context.Background()
+	switch n.DB.DatabaseType {
+	case "postgres", "postgresql", "pgx":
+		conn, err := n.DB.Pool.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		drv, err := migratepostgres.WithConnection(ctx, conn, &migratepostgres.Config{})
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		d.Driver, d.db = drv, conn
+
+	case "mysql", "mariadb":
+		// sql migration files may hold several statements, which the application's pool refuses
+		cfg := n.databaseConfig().mysqlConfig()
+		cfg.MultiStatements = true
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		pool := sql.OpenDB(connector)
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			_ = pool.Close()
+			return nil, err
+		}
+		drv, err := migratemysql.WithConnection(ctx, conn, &migratemysql.Config{})
+		if err != nil {
+			_ = conn.Close()
+			_ = pool.Close()
+			return nil, err
+		}
+		d.Driver, d.db, d.pool = drv, conn, pool
+
+	case "sqlite":
+		// the sqlite driver only runs on a whole pool. Each connection to an in-memory database has
+		// a database of its own, so the pool must be limited to one connection.
+		if n.Config.Database.Name == ":memory:" && n.DB.Pool.Stats().MaxOpenConnections != 1 {
+			return nil, errors.New("go migrations on an in-memory sqlite database need a pool limited to one connection")
+		}
+		drv, err := migratesqlite.WithInstance(n.DB.Pool, &migratesqlite.Config{})
+		if err != nil {
+			return nil, err
+		}
+		d.Driver, d.db, d.keepOpen = drv, n.DB.Pool, true
+
+	default:
+		return nil, fmt.Errorf("go migrations are not supported on %s databases", n.DB.DatabaseType)
+	}
+
+	return d, nil
+}
+
+func (d *goMigrationDriver) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(body, []byte(goMigrationMarker)) {
+		return d.Driver.Run(bytes.NewReader(body))
+	}
+
+	var direction string
+	var version uint
+	_, err = fmt.Sscanf(string(body[len(goMigrationMarker):]), "%s %d", &direction, &version)
+	if err != nil {
+		return fmt.Errorf("invalid go migration marker: %w", err)
+	}
+
+	m := d.migrations[version]
+	fn := m.Up
+	if direction == "down" {
+		fn = m.Down
+	}
+	if fn == nil {
+		return fmt.Errorf("go migration %d has no %s function", version, direction)
+	}
+	if d.db == nil {
+		return fmt.Errorf("go migration %d needs the application's database connection", version)
+	}
+
+	return runTxOn(context.Background(), d.db, nil, fn)
+}
+
+// Close releases the driver's connection, leaving the application's pool open
+func (d *goMigrationDriver) Close() error {
+	if d.keepOpen {
+		return nil
+	}
+
+	err := d.Driver.Close()
+	if d.pool != nil {
+		err = errors.Join(err, d.pool.Close())
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package navitas
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNavitas_GoMigrations(t *testing.T) {
+	root := t.TempDir()
+	dbFile := filepath.Join(root, "test.db")
+
+	err := os.MkdirAll(filepath.Join(root, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"1_create_users.up.sql":   "create table users (id integer primary key, name text);",
+		"1_create_users.down.sql": "drop table users;",
+		"3_create_posts.up.sql":   "create table posts (id integer primary key);",
+		"3_create_posts.down.sql": "drop table posts;",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, "migrations", name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := &Navitas{RootPath: root, DB: Database{DatabaseType: "sqlite"}}
+	n.DB.Pool, err = sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.DB.Pool.Close()
+
+	n.AddGoMigration(GoMigration{
+		Version: 2,
+		Name:    "backfill_users",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("insert into users (name) values ('alice'), ('bob')")
+			return err
+		},
+		Down: func(tx *sql.Tx) error {
+			_, err := tx.Exec("delete from users")
+			return err
+		},
+	})
+
+	dsn := "sqlite://" + dbFile
+	if err := n.MigrateUp(dsn); err != nil {
+		t.Fatal("unexpected error migrating:", err)
+	}
+
+	var users int
+	if err := n.DB.Pool.QueryRow("select count(*) from users").Scan(&users); err != nil || users != 2 {
+		t.Errorf("expected go migration to backfill two users, got %d: %v", users, err)
+	}
+
+	status, err := n.MigrationStatus(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 3 || len(status.Migrations) != 3 || status.Migrations[1].Name != "backfill_users" {
+		t.Errorf("expected go migration to be tracked with the sql files, got %+v", status)
+	}
+
+	if err := n.MigrateDown(2, dsn); err != nil {
+		t.Fatal("unexpected error migrating down:", err)
+	}
+	if err := n.DB.Pool.QueryRow("select count(*) from users").Scan(&users); err != nil || users != 0 {
+		t.Errorf("expected go down migration to remove users, got %d: %v", users, err)
+	}
+
+	n.AddGoMigration(GoMigration{Version: 2, Name: "failing", Up: func(tx *sql.Tx) error {
+		return errors.New("boom")
+	}})
+	if err := n.MigrateUp(dsn); err == nil {
+		t.Error("expected failing go migration to return an error")
+	}
+	status, _ = n.MigrationStatus(dsn)
+	if !status.Dirty || status.Version != 2 {
+		t.Errorf("expected failed go migration to leave version 2 dirty, got %+v", status)
+	}
+}
+
+func TestNavitas_GoMigrationsInMemory(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(root, "migrations", "1_create_users.up.sql"), []byte("create table users (name text);"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	n := &Navitas{RootPath: root, DB: Database{DatabaseType: "sqlite", Pool: pool}}
+	n.Config.Database = DatabaseConfig{Type: "sqlite", Name: ":memory:"}
+	n.AddGoMigration(GoMigration{
+		Version: 2,
+		Name:    "backfill_users",
+		Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("insert into users (name) values ('alice')")
+			return err
+		},
+	})
+
+	// every connection to :memory: is a separate database, so the step could miss the versioned one
+	dsn := n.BuildMigrationURL()
+	if err := n.MigrateUp(dsn); err == nil {
+		t.Fatal("expected an error running Go migrations on an unlimited in-memory pool")
+	}
+
+	pool.SetMaxOpenConns(1)
+	if err := n.MigrateUp(dsn); err != nil {
+		t.Fatal("unexpected error migrating:", err)
+	}
+
+	var users int
+	if err := pool.QueryRow("select count(*) from users").Scan(&users); err != nil || users != 1 {
+		t.Errorf("expected the Go migration to run on the migrated database, got %d: %v", users, err)
+	}
+
+	status, err := n.MigrationStatus(dsn)
+	if err != nil || status.Version != 2 || status.Dirty {
+		t.Errorf("expected the in-memory database to be at version 2, got %+v: %v", status, err)
+	}
+}
@@ -0,0 +1,180 @@
+package navitas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthCheck reports whether a dependency is available. It should return promptly once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// pinger is implemented by caches and file systems that can check their own connection
+type pinger interface {
+	Ping() error
+}
+
+// healthResult is the outcome of one readiness check, as reported by /readyz
+type healthResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type healthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]healthResult `json:"checks,omitempty"`
+}
+
+// AddHealthCheck registers a named readiness check, which is run on every request to /readyz
+// alongside the built in database, cache, file system and mail checks. Adding a check with an
+// existing name replaces it.
+func (n *Navitas) AddHealthCheck(name string, check HealthCheck) {
+	n.healthMu.Lock()
+	defer n.healthMu.Unlock()
+
+	if n.healthChecks == nil {
+		n.healthChecks = make(map[string]HealthCheck)
+	}
+	n.healthChecks[name] = check
+}
+
+// registerHealthChecks adds readiness checks for every configured dependency
+func (n *Navitas) registerHealthChecks() {
+	if n.DB.Pool != nil {
+		n.AddHealthCheck("database", func(ctx context.Context) error {
+			return n.DB.Pool.PingContext(ctx)
+		})
+	}
+	for name, db := range n.DBs {
+		if db.Pool != nil {
+			n.AddHealthCheck("database:"+name, db.Pool.PingContext)
+		}
+	}
+
+	if p, ok := n.Cache.(pinger); ok {
+		n.AddHealthCheck("cache", pingCheck(p))
+	}
+
+	if n.redisPool != nil && n.Config.SessionType == "redis" && n.Config.Cache != "redis" {
+		n.AddHealthCheck("redis", func(ctx context.Context) error {
+			conn, err := n.redisPool.GetContext(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			_, err = conn.Do("PING")
+			return err
+		})
+	}
+
+	if _, ok := n.FileSystems["S3"]; ok {
+		n.AddHealthCheck("filesystem:s3", n.S3.Ping)
+	}
+	if _, ok := n.FileSystems["MINIO"]; ok {
+		n.AddHealthCheck("filesystem:minio", n.Minio.Ping)
+	}
+	if _, ok := n.FileSystems["SFTP"]; ok {
+		n.AddHealthCheck("filesystem:sftp", n.SFTP.Ping)
+	}
+	if _, ok := n.FileSystems["WEBDAV"]; ok {
+		n.AddHealthCheck("filesystem:webdav", pingCheck(&n.WebDAV))
+	}
+
+	if n.Config.Mail.Host != "" {
+		n.AddHealthCheck("mail", pingCheck(&n.Mail))
+	}
+}
+
+// pingCheck adapts a Ping method that does not take a context into a HealthCheck. The Ping keeps
+// running if the check times out, so Ping methods that take a context are registered directly.
+func pingCheck(p pinger) HealthCheck {
+	return func(ctx context.Context) error {
+		done := make(chan error, 1)
+		go func() {
+			done <- p.Ping()
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Liveness responds 200 as long as the process is able to serve requests
+func (n *Navitas) Liveness(w http.ResponseWriter, r *http.Request) {
+	_ = n.WriteJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readiness runs every registered health check concurrently, each with its own timeout, and
+// responds 200 if they all pass or 503 with the failing checks marked if any do not
+func (n *Navitas) Readiness(w http.ResponseWriter, r *http.Request) {
+	n.healthMu.RLock()
+	checks := make(map[string]HealthCheck, len(n.healthChecks))
+	for name, check := range n.healthChecks {
+		checks[name] = check
+	}
+	n.healthMu.RUnlock()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]healthResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = n.runHealthCheck(r.Context(), check)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	resp := healthResponse{
+		Status: "ok",
+		Checks: make(map[string]healthResult, len(names)),
+	}
+	status := http.StatusOK
+
+	for i, name := range names {
+		resp.Checks[name] = results[i]
+		if results[i].Status != "ok" {
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	_ = n.WriteJSON(w, status, resp)
+}
+
+func (n *Navitas) runHealthCheck(ctx context.Context, check HealthCheck) (result healthResult) {
+	timeout := n.Config.Health.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			result = healthResult{Status: "error", Error: fmt.Sprint(rec)}
+		}
+		result.Duration = time.Since(start).String()
+	}()
+
+	if err := check(ctx); err != nil {
+		return healthResult{Status: "error", Error: err.Error()}
+	}
+	return healthResult{Status: "ok"}
+}
@@ -0,0 +1,93 @@
+package navitas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNavitas_Readiness(t *testing.T) {
+	n := &Navitas{}
+	n.Config.Health.Timeout = 50 * time.Millisecond
+
+	n.AddHealthCheck("good", func(ctx context.Context) error {
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	n.Readiness(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Error("expected 200 when all checks pass, got", w.Code)
+	}
+
+	n.AddHealthCheck("broken", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	n.AddHealthCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	w = httptest.NewRecorder()
+	n.Readiness(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Error("expected 503 when a check fails, got", w.Code)
+	}
+
+	var resp healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"good": "ok", "broken": "error", "slow": "error"}
+	for name, status := range expected {
+		if resp.Checks[name].Status != status {
+			t.Errorf("%s: expected status %s and got %s", name, status, resp.Checks[name].Status)
+		}
+	}
+}
+
+func TestNavitas_ProbesAllowAppMiddleware(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{"HEALTH_ENDPOINTS": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	// applications add their own middleware to Routes after init, which chi only allows while
+	// the mux has no routes
+	n.Routes.Use(func(next http.Handler) http.Handler {
+		return next
+	})
+	n.Routes.Get("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// as an application running its own http.Server would
+	server := httptest.NewServer(n.Handler())
+	defer server.Close()
+
+	for path, expected := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/readyz":  http.StatusOK,
+		"/page":    http.StatusNoContent,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("%s: expected status %d and got %d", path, expected, resp.StatusCode)
+		}
+	}
+}
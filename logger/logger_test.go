@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_Write(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxSize:  10,
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(rotated) == 0 {
+		t.Error("expected the log file to be rotated")
+	}
+
+	current, err := os.ReadFile(r.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(current) != "third\n" {
+		t.Errorf("expected current log to only contain the last write, got %q", current)
+	}
+}
+
+func TestRotatingFile_RotationsDoNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxSize:  1,
+	}
+	defer r.Close()
+
+	// every write rotates, many of them within the same millisecond
+	lines := 50
+	for i := 0; i < lines; i++ {
+		if _, err := r.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(rotated) != lines-1 {
+		t.Errorf("expected %d rotated files, got %d", lines-1, len(rotated))
+	}
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxAge:   time.Hour,
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	r.opened = time.Now().Add(-2 * time.Hour)
+	if _, err := r.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(rotated) != 1 {
+		t.Fatalf("expected the log file to be rotated once it was older than MaxAge, got %d rotated files", len(rotated))
+	}
+
+	current, err := os.ReadFile(r.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "second\n" {
+		t.Errorf("expected current log to only contain the write after rotating, got %q", current)
+	}
+}
+
+func TestRotatingFile_RemovesOnlyRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	r := &RotatingFile{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxSize:  1,
+		MaxAge:   time.Hour,
+	}
+	defer r.Close()
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"app-2020-01-02T03-04-05.000.log", "app-2020-01-02T03-04-05.000.1.log", "app-debug.log"} {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fileName, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, kept := range map[string]bool{
+		"app-2020-01-02T03-04-05.000.log":   false,
+		"app-2020-01-02T03-04-05.000.1.log": false,
+		"app-debug.log":                     true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if kept && err != nil {
+			t.Errorf("expected %s to be left alone: %v", name, err)
+		}
+		if !kept && !os.IsNotExist(err) {
+			t.Errorf("expected expired %s to be removed", name)
+		}
+	}
+}
+
+func TestRotatedName_StatError(t *testing.T) {
+	// a name too long for the file system fails to stat with an error other than not existing
+	base := filepath.Join(t.TempDir(), strings.Repeat("a", 300))
+
+	if _, err := rotatedName(base, ".log", time.Now()); err == nil {
+		t.Error("expected an error for a name that cannot be checked")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	fallback := New(&buf, slog.LevelInfo, "text")
+
+	if FromContext(context.Background(), fallback) != fallback {
+		t.Error("expected fallback logger for a context without one")
+	}
+
+	l := fallback.With("request_id", "abc")
+	ctx := WithLogger(context.Background(), l)
+	FromContext(ctx, fallback).Info("hello")
+
+	if !strings.Contains(buf.String(), "request_id=abc") {
+		t.Error("expected request scoped attributes in log output, got", buf.String())
+	}
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.WriteCloser that writes to Filename, moving it aside to a timestamped
+// file once it grows past MaxSize or has been open for longer than MaxAge, and deleting rotated
+// files older than MaxAge.
+type RotatingFile struct {
+	Filename string
+	MaxSize  int64         // bytes; zero disables rotation by size
+	MaxAge   time.Duration // zero disables rotation by age, and keeps rotated files forever
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// Write appends p to the current log file, rotating first if p would take it past MaxSize, or if
+// it has been open for longer than MaxAge
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	tooBig := r.MaxSize > 0 && r.size+int64(len(p)) > r.MaxSize
+	tooOld := r.MaxAge > 0 && time.Since(r.opened) > r.MaxAge
+	if r.size > 0 && (tooBig || tooOld) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	err := os.MkdirAll(filepath.Dir(r.Filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.file = f
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.Filename)
+	base := strings.TrimSuffix(r.Filename, ext)
+	rotated, err := rotatedName(base, ext, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(r.Filename, rotated); err != nil {
+		return err
+	}
+
+	r.removeExpired(base, ext)
+
+	return r.open()
+}
+
+// rotatedStamp matches the part of a name that rotatedName puts between the base and extension
+var rotatedStamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}(\.\d+)?$`)
+
+// rotatedName returns an unused name for a file rotated at t. Rotations within the same
+// millisecond get a sequence number, so none of them overwrites another.
+func rotatedName(base, ext string, t time.Time) (string, error) {
+	stamp := fmt.Sprintf("%s-%s", base, t.Format("2006-01-02T15-04-05.000"))
+	name := stamp + ext
+	for i := 1; ; i++ {
+		_, err := os.Lstat(name)
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s.%d%s", stamp, i, ext)
+	}
+}
+
+// removeExpired deletes rotated files older than MaxAge
+func (r *RotatingFile) removeExpired(base, ext string) {
+	if r.MaxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-r.MaxAge)
+	for _, m := range matches {
+		// the glob also matches other files that share the log's name, such as app-debug.log
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, base+"-"), ext)
+		if !rotatedStamp.MatchString(stamp) {
+			continue
+		}
+
+		info, err := os.Stat(m)
+		if err == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(m)
+		}
+	}
+}
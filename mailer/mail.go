@@ -6,8 +6,11 @@ import (
 	"html/template"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	apimail "github.com/ainsleyclark/go-mail"
@@ -33,6 +36,16 @@ type Mail struct {
 	API         string
 	APIKey      string
 	APIUrl      string
+	sent        uint64
+	failed      uint64
+}
+
+// Stats reports how many messages are waiting in the Jobs channel, and how many
+// ListenForMail has sent successfully or failed to send
+type Stats struct {
+	Queued int
+	Sent   uint64
+	Failed uint64
 }
 
 // Message is the type for an email message
@@ -56,19 +69,56 @@ type Result struct {
 // when it receives a payload. It runs continually in the background,
 // and sends error/success messages back on the Results channel.
 // Note that if api and api key are set, it will prefer using
-// an api to send mail
+// an api to send mail. It returns once the Jobs channel has been
+// closed and every queued message has been sent.
 func (m *Mail) ListenForMail() {
+	for msg := range m.Jobs {
+		m.deliver(msg)
+	}
+}
+
+// ListenForMailUntil works like ListenForMail, but returns once stop is closed and the messages
+// queued by then have been sent. Jobs is left open, so sending on it afterwards never panics;
+// the message just stays queued.
+func (m *Mail) ListenForMailUntil(stop <-chan struct{}) {
 	for {
-		msg := <-m.Jobs
-		err := m.Send(msg)
-		if err != nil {
-			m.Results <- Result{false, err}
-		} else {
-			m.Results <- Result{true, nil}
+		select {
+		case msg := <-m.Jobs:
+			m.deliver(msg)
+		case <-stop:
+			for {
+				select {
+				case msg := <-m.Jobs:
+					m.deliver(msg)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
+// deliver sends msg and reports the result on the Results channel
+func (m *Mail) deliver(msg Message) {
+	err := m.Send(msg)
+	if err != nil {
+		atomic.AddUint64(&m.failed, 1)
+		m.Results <- Result{false, err}
+	} else {
+		atomic.AddUint64(&m.sent, 1)
+		m.Results <- Result{true, nil}
+	}
+}
+
+// Stats returns the current queue depth and the number of messages sent and failed
+func (m *Mail) Stats() Stats {
+	return Stats{
+		Queued: len(m.Jobs),
+		Sent:   atomic.LoadUint64(&m.sent),
+		Failed: atomic.LoadUint64(&m.failed),
+	}
+}
+
 // Send sends an email message using correct method. If API values are set,
 // it will send using the appropriate api; otherwise, it sends via smtp
 func (m *Mail) Send(msg Message) error {
@@ -230,6 +280,15 @@ func (m *Mail) SendSMTPMessage(msg Message) error {
 	return nil
 }
 
+// Ping checks that the SMTP server accepts connections
+func (m *Mail) Ping() error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.Host, strconv.Itoa(m.Port)), 5*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // getEncryption returns the appropriate encryption type based on a string value
 func (m *Mail) getEncryption(e string) simple_mail.Encryption {
 	switch e {
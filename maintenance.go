@@ -0,0 +1,200 @@
+package navitas
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maintenanceBypassCookie is set for staff who visit the site with ?maintenance_bypass=<secret>
+const maintenanceBypassCookie = "navitas_maintenance_bypass"
+
+// peerAddrKey is the context key under which RememberPeer stores the connection's remote address
+type peerAddrKey struct{}
+
+// InMaintenanceMode reports whether the application is currently down for maintenance
+func (n *Navitas) InMaintenanceMode() bool {
+	return n.maintenance.Load()
+}
+
+// SetMaintenanceMode turns maintenance mode on or off. The state is persisted to
+// RootPath/tmp/maintenance so that a restart during maintenance stays down.
+func (n *Navitas) SetMaintenanceMode(on bool) error {
+	fileName := n.maintenanceFile()
+
+	if on {
+		err := os.MkdirAll(filepath.Dir(fileName), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fileName, []byte("down"), 0644)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := os.Remove(fileName)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	n.maintenance.Store(on)
+	return nil
+}
+
+// CheckForMaintenanceMode responds with 503 Service Unavailable while the application is in
+// maintenance mode, unless the client is on the allowed ip list or holds the bypass cookie.
+// If a view named "maintenance" exists it is rendered as the response body. Health probes and
+// metrics scrapes are answered before this middleware runs, so they are never blocked and the
+// orchestrator does not restart an application that is down on purpose.
+func (n *Navitas) CheckForMaintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !n.InMaintenanceMode() || n.canBypassMaintenance(w, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(n.Config.Maintenance.RetryAfter))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		if n.Render != nil {
+			if err := n.Render.Page(w, r, "maintenance", nil, nil); err == nil {
+				return
+			}
+		}
+		_, _ = w.Write([]byte(http.StatusText(http.StatusServiceUnavailable)))
+	})
+}
+
+// canBypassMaintenance checks the allowed ip list and the bypass secret. A correct secret
+// supplied in the query string sets the bypass cookie, so staff only need to use it once.
+func (n *Navitas) canBypassMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	if ipAllowed(n.Config.Maintenance.AllowedIPs, n.maintenanceClientAddr(r)) {
+		return true
+	}
+
+	secret := n.Config.Maintenance.Secret
+	if secret == "" {
+		return false
+	}
+
+	token := n.maintenanceBypassToken()
+	if cookie, err := r.Cookie(maintenanceBypassCookie); err == nil && hmac.Equal([]byte(cookie.Value), []byte(token)) {
+		return true
+	}
+
+	if secretMatches(r.URL.Query().Get("maintenance_bypass"), secret) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     maintenanceBypassCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   n.Config.Cookie.Secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return true
+	}
+
+	return false
+}
+
+// RememberPeer records the address of the connection's peer before middleware.RealIP replaces
+// RemoteAddr with whatever the client put in X-Forwarded-For or X-Real-IP. It must run before RealIP.
+func (n *Navitas) RememberPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// maintenanceClientAddr returns the address checked against the allowed ip list. Forwarded
+// headers are only believed when the connection comes from a trusted proxy; the client is then
+// the right-most X-Forwarded-For hop that is not itself a trusted proxy.
+func (n *Navitas) maintenanceClientAddr(r *http.Request) string {
+	peer, ok := r.Context().Value(peerAddrKey{}).(string)
+	if !ok {
+		peer = r.RemoteAddr
+	}
+
+	trusted := n.Config.Maintenance.TrustedProxies
+	if !ipAllowed(trusted, peer) {
+		return peer
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop != "" && !ipAllowed(trusted, hop) {
+			return hop
+		}
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return realIP
+	}
+
+	return peer
+}
+
+func (n *Navitas) maintenanceFile() string {
+	return filepath.Join(n.RootPath, "tmp", "maintenance")
+}
+
+func (n *Navitas) maintenanceFileExists() bool {
+	_, err := os.Stat(n.maintenanceFile())
+	return err == nil
+}
+
+// maintenanceBypassToken is the value of the bypass cookie: an hmac of the secret keyed with the
+// application's encryption key, so the cookie does not reveal the secret itself
+func (n *Navitas) maintenanceBypassToken() string {
+	mac := hmac.New(sha256.New, []byte(n.Config.EncryptionKey))
+	mac.Write([]byte(n.Config.Maintenance.Secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func secretMatches(supplied, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(supplied), []byte(secret)) == 1
+}
+
+// ipAllowed reports whether remoteAddr matches one of the allowed ip addresses or cidr ranges
+func ipAllowed(allowed []string, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, a := range allowed {
+		if _, network, err := net.ParseCIDR(a); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validIPOrCIDR(s string) bool {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return true
+	}
+	return net.ParseIP(s) != nil
+}
@@ -0,0 +1,154 @@
+package navitas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestNavitas_CheckForMaintenanceMode(t *testing.T) {
+	n := &Navitas{RootPath: t.TempDir()}
+	n.Config.EncryptionKey = "abcdefghijklmnopqrstuvwxyz123456"
+	n.Config.Maintenance = MaintenanceConfig{
+		RetryAfter: 120,
+		AllowedIPs: []string{"10.0.0.0/8"},
+		Secret:     "letmein",
+	}
+
+	handler := n.CheckForMaintenanceMode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if err := n.SetMaintenanceMode(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		url        string
+		expected   int
+	}{
+		{"blocked", "192.168.1.10:1234", "/", http.StatusServiceUnavailable},
+		{"allowed_ip", "10.1.2.3:1234", "/", http.StatusOK},
+		{"wrong_secret", "192.168.1.10:1234", "/?maintenance_bypass=nope", http.StatusServiceUnavailable},
+		{"secret", "192.168.1.10:1234", "/?maintenance_bypass=letmein", http.StatusOK},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", e.url, nil)
+		r.RemoteAddr = e.remoteAddr
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.expected {
+			t.Errorf("%s: expected status %d and got %d", e.name, e.expected, w.Code)
+		}
+
+		if w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "120" {
+			t.Errorf("%s: wrong Retry-After header %q", e.name, w.Header().Get("Retry-After"))
+		}
+	}
+
+	// the bypass cookie set by the secret lets later requests through, without holding the secret
+	r := httptest.NewRequest("GET", "/?maintenance_bypass=letmein", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var bypass *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == maintenanceBypassCookie {
+			bypass = c
+		}
+	}
+	if bypass == nil {
+		t.Fatal("expected the secret to set the bypass cookie")
+	}
+	if strings.Contains(bypass.Value, "letmein") {
+		t.Error("expected the bypass cookie not to contain the secret")
+	}
+
+	for _, cookie := range []struct {
+		value    string
+		expected int
+	}{
+		{bypass.Value, http.StatusOK},
+		{"letmein", http.StatusServiceUnavailable},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: maintenanceBypassCookie, Value: cookie.value})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != cookie.expected {
+			t.Errorf("bypass cookie %q: expected status %d and got %d", cookie.value, cookie.expected, w.Code)
+		}
+	}
+
+	// a new instance in the same root path should start in maintenance mode
+	restarted := &Navitas{RootPath: n.RootPath}
+	if !restarted.maintenanceFileExists() {
+		t.Error("maintenance mode was not persisted")
+	}
+
+	if err := n.SetMaintenanceMode(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.maintenanceFileExists() {
+		t.Error("maintenance mode file was not removed")
+	}
+}
+
+func TestNavitas_CheckForMaintenanceMode_ForwardedFor(t *testing.T) {
+	n := &Navitas{RootPath: t.TempDir()}
+	n.Config.Maintenance = MaintenanceConfig{
+		AllowedIPs:     []string{"10.0.0.0/8"},
+		TrustedProxies: []string{"172.16.0.1"},
+	}
+
+	// the same middleware order as routes: RealIP rewrites RemoteAddr from the forwarded headers
+	handler := n.RememberPeer(middleware.RealIP(n.CheckForMaintenanceMode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	if err := n.SetMaintenanceMode(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expected     int
+	}{
+		{"spoofed_forwarded_for", "192.168.1.10:1234", "10.1.2.3", "", http.StatusServiceUnavailable},
+		{"spoofed_real_ip", "192.168.1.10:1234", "", "10.1.2.3", http.StatusServiceUnavailable},
+		{"trusted_proxy", "172.16.0.1:1234", "10.1.2.3", "", http.StatusOK},
+		{"trusted_proxy_spoofed_hop", "172.16.0.1:1234", "10.1.2.3, 192.168.1.10", "", http.StatusServiceUnavailable},
+		{"trusted_proxy_blocked_client", "172.16.0.1:1234", "192.168.1.10", "", http.StatusServiceUnavailable},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = e.remoteAddr
+		if e.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", e.forwardedFor)
+		}
+		if e.realIP != "" {
+			r.Header.Set("X-Real-IP", e.realIP)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		if w.Code != e.expected {
+			t.Errorf("%s: expected status %d and got %d", e.name, e.expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package navitas
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bmozi/navitas/cache"
+	"github.com/bmozi/navitas/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/robfig/cron/v3"
+)
+
+// unmatchedRoute labels requests that never reached a route: 404s, and requests rejected by
+// middleware such as maintenance mode or csrf protection
+const unmatchedRoute = "unmatched"
+
+// startMetrics creates the metrics registry and the http request collectors used by CollectMetrics
+func (n *Navitas) startMetrics() {
+	n.Metrics = metrics.NewRegistry()
+	n.httpRequests = n.Metrics.NewCounter("navitas_http_requests_total",
+		"Number of http requests, by route pattern, method and status.", "route", "method", "status")
+	n.httpDuration = n.Metrics.NewHistogram("navitas_http_request_duration_seconds",
+		"Http request latency in seconds, by route pattern and method.", metrics.DefaultBuckets, "route", "method")
+}
+
+// CollectMetrics records the count and latency of every request, labelled with the chi route
+// pattern rather than the raw path so that ids in urls do not create unbounded series
+func (n *Navitas) CollectMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		n.httpRequests.Inc(route, r.Method, strconv.Itoa(ww.Status()))
+		n.httpDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// metricsHandler serves the metrics registry, requiring METRICS_TOKEN as a bearer token when it
+// is set
+func (n *Navitas) metricsHandler() http.Handler {
+	handler := n.Metrics.Handler()
+	token := n.Config.Metrics.Token
+	if token == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		supplied, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !secretMatches(supplied, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// countCronRuns is a cron job wrapper that counts every scheduled job run
+func (n *Navitas) countCronRuns(j cron.Job) cron.Job {
+	return cron.FuncJob(func() {
+		atomic.AddUint64(&n.cronRuns, 1)
+		j.Run()
+	})
+}
+
+// registerMetrics adds collectors for the database pool, redis pool, cache, mail queue and
+// scheduler. Values are read when /metrics is scraped.
+func (n *Navitas) registerMetrics() {
+	if n.DB.Pool != nil {
+		pool := n.DB.Pool
+		n.Metrics.NewGaugeFunc("navitas_db_max_open_connections", "Maximum number of open database connections.", func() float64 {
+			return float64(pool.Stats().MaxOpenConnections)
+		})
+		n.Metrics.NewGaugeFunc("navitas_db_open_connections", "Number of open database connections.", func() float64 {
+			return float64(pool.Stats().OpenConnections)
+		})
+		n.Metrics.NewGaugeFunc("navitas_db_in_use_connections", "Number of database connections in use.", func() float64 {
+			return float64(pool.Stats().InUse)
+		})
+		n.Metrics.NewGaugeFunc("navitas_db_idle_connections", "Number of idle database connections.", func() float64 {
+			return float64(pool.Stats().Idle)
+		})
+		n.Metrics.NewCounterFunc("navitas_db_wait_count_total", "Number of times a database connection was waited for.", func() float64 {
+			return float64(pool.Stats().WaitCount)
+		})
+		n.Metrics.NewCounterFunc("navitas_db_wait_duration_seconds_total", "Total time spent waiting for a database connection.", func() float64 {
+			return pool.Stats().WaitDuration.Seconds()
+		})
+
+		if len(n.Config.Database.Replicas) > 0 {
+			n.Metrics.NewGaugeFunc("navitas_db_healthy_replicas", "Number of read replicas in rotation.", func() float64 {
+				return float64(n.DB.HealthyReplicas())
+			})
+		}
+	}
+
+	if n.redisPool != nil {
+		pool := n.redisPool
+		n.Metrics.NewGaugeFunc("navitas_redis_active_connections", "Number of redis connections in the pool, including idle ones.", func() float64 {
+			return float64(pool.Stats().ActiveCount)
+		})
+		n.Metrics.NewGaugeFunc("navitas_redis_idle_connections", "Number of idle redis connections in the pool.", func() float64 {
+			return float64(pool.Stats().IdleCount)
+		})
+	}
+
+	if c, ok := n.Cache.(interface{ Stats() cache.Stats }); ok {
+		n.Metrics.NewCounterFunc("navitas_cache_hits_total", "Number of cache lookups that found a value.", func() float64 {
+			return float64(c.Stats().Hits)
+		})
+		n.Metrics.NewCounterFunc("navitas_cache_misses_total", "Number of cache lookups that did not find a value.", func() float64 {
+			return float64(c.Stats().Misses)
+		})
+	}
+
+	n.Metrics.NewGaugeFunc("navitas_mail_queue_depth", "Number of messages waiting to be sent.", func() float64 {
+		return float64(n.Mail.Stats().Queued)
+	})
+	n.Metrics.NewCounterFunc("navitas_mail_sent_total", "Number of messages sent.", func() float64 {
+		return float64(n.Mail.Stats().Sent)
+	})
+	n.Metrics.NewCounterFunc("navitas_mail_failures_total", "Number of messages that failed to send.", func() float64 {
+		return float64(n.Mail.Stats().Failed)
+	})
+
+	n.Metrics.NewCounterFunc("navitas_cron_job_runs_total", "Number of scheduled job runs.", func() float64 {
+		return float64(atomic.LoadUint64(&n.cronRuns))
+	})
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("app_requests_total", "Number of requests.\nBy path \\ method.", "path", "method")
+	requests.Inc("/users", "GET")
+	requests.Add(2, "/users", "GET")
+	requests.Inc(`/say "hi"\now`+"\n", "POST")
+
+	// buckets are given out of order, and written in ascending order
+	latency := r.NewHistogram("app_latency_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "method")
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.3, "GET")
+	latency.Observe(2, "GET")
+	latency.Observe(0.5, "POST")
+
+	r.NewGaugeFunc("app_queue_depth", "Messages waiting.", func() float64 { return 4 })
+	r.NewCounterFunc("app_runs_total", "Job runs.", func() float64 { return 1.5 })
+
+	var b bytes.Buffer
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("expected WriteTo to report %d bytes, got %d", b.Len(), n)
+	}
+
+	expected, err := os.ReadFile("testdata/registry.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(expected) {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("app_requests_total", "Number of requests.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Error("wrong content type:", ct)
+	}
+
+	expected := "# HELP app_requests_total Number of requests.\n# TYPE app_requests_total counter\napp_requests_total 1\n"
+	if w.Body.String() != expected {
+		t.Errorf("unexpected output:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,229 @@
+package navitas
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestNavitas_Metrics(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{"METRICS_ENABLED": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	// the metrics path is not on Routes, so the application can still add middleware to it
+	n.Routes.Use(func(next http.Handler) http.Handler {
+		return next
+	})
+	n.Routes.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	// as an application running its own http.Server would
+	server := httptest.NewServer(n.Handler())
+	defer server.Close()
+
+	for _, id := range []string{"1", "2", "3"} {
+		resp, err := http.Get(server.URL + "/users/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("expected 200 from /metrics, got", resp.StatusCode)
+	}
+
+	body := string(data)
+	expected := []string{
+		`navitas_http_requests_total{route="/users/{id}",method="GET",status="418"} 3`,
+		`navitas_http_request_duration_seconds_count{route="/users/{id}",method="GET"} 3`,
+		`# TYPE navitas_http_request_duration_seconds histogram`,
+		`navitas_mail_queue_depth 0`,
+		`navitas_cron_job_runs_total 0`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Errorf("expected metrics output to contain %q", e)
+		}
+	}
+}
+
+func TestNavitas_MetricsUnmatchedRoutes(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{"METRICS_ENABLED": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	n.Routes.Post("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	// a request for no route, and one rejected by the csrf middleware before reaching its route
+	n.Routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	n.Routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", nil))
+
+	w := httptest.NewRecorder()
+	n.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	for _, e := range []string{
+		`navitas_http_requests_total{route="unmatched",method="GET",status="404"} 1`,
+		`navitas_http_requests_total{route="unmatched",method="POST",status="400"} 1`,
+	} {
+		if !strings.Contains(body, e) {
+			t.Errorf("expected metrics output to contain %q", e)
+		}
+	}
+}
+
+func TestNavitas_MetricsCountCronRuns(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{"METRICS_ENABLED": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	ran := make(chan struct{})
+	var once sync.Once
+	n.Scheduler.Schedule(tickSchedule{}, cron.FuncJob(func() {
+		once.Do(func() { close(ran) })
+	}))
+
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the scheduled job to run")
+	}
+
+	w := httptest.NewRecorder()
+	n.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		value, ok := strings.CutPrefix(line, "navitas_cron_job_runs_total ")
+		if !ok {
+			continue
+		}
+		if runs, err := strconv.Atoi(value); err != nil || runs < 1 {
+			t.Errorf("expected at least one cron job run to be counted, got %q", value)
+		}
+		return
+	}
+	t.Error("expected navitas_cron_job_runs_total in the metrics output")
+}
+
+func TestNavitas_MetricsToken(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{"METRICS_ENABLED": "true", "METRICS_TOKEN": "scrape-me"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	for _, e := range []struct {
+		authorization string
+		expected      int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"scrape-me", http.StatusUnauthorized},
+		{"Bearer scrape-me", http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if e.authorization != "" {
+			r.Header.Set("Authorization", e.authorization)
+		}
+		w := httptest.NewRecorder()
+		n.Handler().ServeHTTP(w, r)
+
+		if w.Code != e.expected {
+			t.Errorf("authorization %q: expected status %d and got %d", e.authorization, e.expected, w.Code)
+		}
+	}
+}
+
+func TestNavitas_MetricsPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metricsPort, _ := net.SplitHostPort(l.Addr().String())
+	_ = l.Close()
+
+	cfg, err := loadConfig(envFrom(map[string]string{"METRICS_ENABLED": "true", "METRICS_PORT": metricsPort}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, served := serveTestApp(t, n)
+	defer func() {
+		_ = n.Shutdown(context.Background())
+		<-served
+	}()
+
+	resp, err := http.Get("http://127.0.0.1:" + port + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("expected metrics not to be served on the public port, got", resp.StatusCode)
+	}
+
+	var body []byte
+	for i := 0; i < 100; i++ {
+		resp, err = http.Get("http://127.0.0.1:" + metricsPort + "/metrics")
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "navitas_http_requests_total") {
+		t.Errorf("expected metrics on the metrics port, got %d: %s", resp.StatusCode, body)
+	}
+}
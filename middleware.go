@@ -1,30 +1,88 @@
 package navitas
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"strconv"
+	"time"
 
+	"github.com/bmozi/navitas/logger"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/nosurf"
 )
 
 func (n *Navitas) SessionLoad(next http.Handler) http.Handler {
 	n.InfoLog.Println("SessionLoad called")
-	return n.Session.LoadAndSave(next)
+	return n.Session.LoadAndSave(n.addUserToLogger(next))
+}
+
+// RequestLogger stores a logger in the request context that carries the request id, method and
+// path, and logs each completed request with its status and duration at debug level. Handlers
+// retrieve it with LoggerFromContext.
+func (n *Navitas) RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		l := n.Logger.With(
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(logger.WithLogger(r.Context(), l)))
+
+		l.Debug("request completed",
+			slog.Int("status", ww.Status()),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// addUserToLogger adds the authenticated user's id to the request logger once the session is loaded
+func (n *Navitas) addUserToLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := n.Session.Get(r.Context(), "userID"); userID != nil {
+			l := n.LoggerFromContext(r.Context()).With(slog.Any("user_id", userID))
+			r = r.WithContext(logger.WithLogger(r.Context(), l))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoggerFromContext returns the request scoped logger added by RequestLogger, or the
+// application logger if ctx does not carry one
+func (n *Navitas) LoggerFromContext(ctx context.Context) *slog.Logger {
+	return logger.FromContext(ctx, n.Logger)
 }
 
 func (n *Navitas) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
-	secure, _ := strconv.ParseBool(n.config.cookie.secure)
 
 	csrfHandler.ExemptGlob("/api/*")
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   secure,
+		Secure:   n.Config.Cookie.Secure,
 		SameSite: http.SameSiteStrictMode,
-		Domain:   n.config.cookie.domain,
+		Domain:   n.Config.Cookie.Domain,
 	})
 
 	return csrfHandler
 }
+
+// HSTS sends the Strict-Transport-Security header, telling browsers to only use HTTPS for this site.
+// It is added to the routes automatically when Secure is true.
+func (n *Navitas) HSTS(next http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", n.Config.TLS.HSTSMaxAge)
+	if n.Config.TLS.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
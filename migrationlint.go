@@ -0,0 +1,274 @@
+package navitas
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// PendingMigration is a migration that has not been applied yet, with the sql it will run
+type PendingMigration struct {
+	Version uint
+	Name    string
+	SQL     string // empty for Go migrations
+	Go      bool
+}
+
+// MigrationIssue is a statement in a pending migration that can lose data or lock a table
+type MigrationIssue struct {
+	Version   uint
+	Name      string
+	Line      int
+	Rule      string // drop-table, drop-column, column-type or index-lock
+	Message   string
+	Statement string
+}
+
+func (i MigrationIssue) String() string {
+	return fmt.Sprintf("%d_%s line %d: %s [%s]", i.Version, i.Name, i.Line, i.Message, i.Rule)
+}
+
+// PendingMigrations returns the up migrations that have not been applied yet, in the order they
+// will run
+func (n *Navitas) PendingMigrations(dsn string) ([]PendingMigration, error) {
+	status, err := n.MigrationStatus(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := n.migrationSource()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var pending []PendingMigration
+	for _, m := range status.Pending() {
+		r, _, err := src.ReadUp(m.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		body, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		p := PendingMigration{Version: m.Version, Name: m.Name}
+		if bytes.HasPrefix(body, []byte(goMigrationMarker)) {
+			p.Go = true
+		} else {
+			p.SQL = string(body)
+		}
+		pending = append(pending, p)
+	}
+	return pending, nil
+}
+
+// LintMigrations checks pending migrations for statements that drop tables or columns, change
+// column types, or, on postgres, build indexes without CONCURRENTLY. Go migrations are not checked.
+func (n *Navitas) LintMigrations(pending []PendingMigration) []MigrationIssue {
+	dialect := driverName(n.databaseConfig().Type)
+
+	var issues []MigrationIssue
+	for _, m := range pending {
+		for _, issue := range LintMigrationSQL(dialect, m.SQL) {
+			issue.Version, issue.Name = m.Version, m.Name
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+var (
+	createTableRe = regexp.MustCompile(`^create (?:temp |temporary )?table (?:if not exists )?(\S+)`)
+	dropTableRe   = regexp.MustCompile(`^drop table (?:if exists )?(.+?)(?: cascade| restrict)?$`)
+	alterTableRe  = regexp.MustCompile(`^alter table (?:if exists )?(?:only )?(\S+)`)
+	dropColumnRe  = regexp.MustCompile(`(?:^alter table (?:if exists )?(?:only )?\S+|,) drop (?:column )?(?:if exists )?(\S+)`)
+	columnTypeRe  = regexp.MustCompile(`\balter (?:column )?\S+ (?:set data )?type |(?:^alter table (?:if exists )?\S+|,) (?:modify|change) `)
+	createIndexRe = regexp.MustCompile(`^create (?:unique )?index (?:(concurrently) )?(?:if not exists )?(?:\S+ )?on (?:only )?([^\s(]+)`)
+)
+
+// notColumns are the words after an alter table drop that name something other than a column
+var notColumns = map[string]bool{
+	"constraint": true, "index": true, "key": true, "primary": true, "foreign": true, "check": true, "partition": true,
+}
+
+// LintMigrationSQL checks the statements in one migration file. dialect is the database driver
+// name: pgx, mysql or sqlite. Statements on a table the same file creates are not reported, since
+// the table is new and holds no data yet.
+func LintMigrationSQL(dialect, sql string) []MigrationIssue {
+	var issues []MigrationIssue
+	created := make(map[string]bool)
+
+	report := func(s sqlStatement, rule, message string) {
+		issues = append(issues, MigrationIssue{Line: s.line, Rule: rule, Message: message, Statement: s.text})
+	}
+
+	for _, s := range splitSQLStatements(sql) {
+		if m := createTableRe.FindStringSubmatch(s.normalized); m != nil {
+			created[unquoteIdent(m[1])] = true
+			continue
+		}
+
+		if m := dropTableRe.FindStringSubmatch(s.normalized); m != nil {
+			for _, table := range strings.Split(m[1], ",") {
+				table = unquoteIdent(strings.TrimSpace(table))
+				if !created[table] {
+					report(s, "drop-table", fmt.Sprintf("drops table %s and all of its data", table))
+				}
+			}
+			continue
+		}
+
+		if m := alterTableRe.FindStringSubmatch(s.normalized); m != nil {
+			table := unquoteIdent(m[1])
+			if created[table] {
+				continue
+			}
+			for _, drop := range dropColumnRe.FindAllStringSubmatch(s.normalized, -1) {
+				if !notColumns[drop[1]] {
+					report(s, "drop-column", fmt.Sprintf("drops column %s from %s and all of its data", unquoteIdent(drop[1]), table))
+				}
+			}
+			if columnTypeRe.MatchString(s.normalized) {
+				report(s, "column-type", fmt.Sprintf("changes a column type on %s, which can rewrite the table or lose data", table))
+			}
+			continue
+		}
+
+		if m := createIndexRe.FindStringSubmatch(s.normalized); m != nil && dialect == "pgx" {
+			table := unquoteIdent(m[2])
+			if m[1] == "" && !created[table] {
+				report(s, "index-lock", fmt.Sprintf("creates an index on %s without CONCURRENTLY, which blocks writes to it until the index is built", table))
+			}
+		}
+	}
+	return issues
+}
+
+// sqlStatement is one statement from a migration file. normalized is lower case, with comments
+// removed, whitespace collapsed, and string literals and dollar quoted bodies emptied.
+type sqlStatement struct {
+	text       string
+	normalized string
+	line       int
+}
+
+// splitSQLStatements splits a migration file on the semicolons that end its statements
+func splitSQLStatements(sql string) []sqlStatement {
+	var statements []sqlStatement
+	var text, norm strings.Builder
+	line, start := 1, 0
+
+	flush := func() {
+		if n := strings.Join(strings.Fields(norm.String()), " "); n != "" {
+			statements = append(statements, sqlStatement{text: strings.TrimSpace(text.String()), normalized: n, line: start})
+		}
+		text.Reset()
+		norm.Reset()
+		start = 0
+	}
+
+	// skipTo copies sql[i:] up to and including end into the statement text, and returns the index
+	// after it
+	skipTo := func(i int, end string) int {
+		j := strings.Index(sql[i:], end)
+		if j < 0 {
+			j = len(sql) - i - len(end)
+		}
+		chunk := sql[i : i+j+len(end)]
+		line += strings.Count(chunk, "\n")
+		text.WriteString(chunk)
+		return i + len(chunk)
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		if start == 0 && !isSQLSpace(c) && c != ';' && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+			start = line
+		}
+
+		switch {
+		case c == ';':
+			flush()
+			i++
+
+		case strings.HasPrefix(sql[i:], "--"):
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				j = len(sql) - i
+			}
+			i += j
+			norm.WriteByte(' ')
+
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 4
+			}
+			line += strings.Count(sql[i:i+end+4], "\n")
+			i += end + 4
+			norm.WriteByte(' ')
+
+		case c == '\'':
+			text.WriteByte('\'')
+			i = skipTo(i+1, "'")
+			norm.WriteString("''")
+
+		case c == '"' || c == '`':
+			from := i
+			text.WriteByte(c)
+			i = skipTo(i+1, string(c))
+			norm.WriteString(strings.ToLower(sql[from:i]))
+
+		case c == '$' && dollarTag(sql[i:]) != "":
+			tag := dollarTag(sql[i:])
+			text.WriteString(tag)
+			i = skipTo(i+len(tag), tag)
+			norm.WriteString("$$")
+
+		default:
+			if c == '\n' {
+				line++
+			}
+			text.WriteByte(c)
+			norm.WriteByte(lowerASCII(c))
+			i++
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// dollarTag returns the postgres dollar quote, such as $$ or $body$, that s starts with
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func unquoteIdent(name string) string {
+	return strings.NewReplacer(`"`, "", "`", "").Replace(name)
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package navitas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintMigrationSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		sql     string
+		rules   []string
+	}{
+		{"create table", "pgx", "create table users (id serial primary key);", nil},
+		{"drop table", "pgx", "drop table if exists users cascade;", []string{"drop-table"}},
+		{"drop before create", "pgx", "drop table if exists users cascade;\ncreate table users (id serial primary key);", []string{"drop-table"}},
+		{"drop several tables", "mysql", "DROP TABLE a, b;", []string{"drop-table", "drop-table"}},
+		{"commented out drop", "pgx", "-- drop table users;\n/* drop table tokens; */\nselect 1;", nil},
+		{"drop in string", "pgx", "insert into notes (body) values ('drop table users;');", nil},
+		{"drop in function body", "pgx", "create function f() returns void as $$ begin drop table users; end; $$ language plpgsql;", nil},
+		{"drop column", "pgx", `ALTER TABLE "users" DROP COLUMN "email";`, []string{"drop-column"}},
+		{"mysql drop column", "mysql", "alter table users drop email, add name varchar(255);", []string{"drop-column"}},
+		{"drop constraint", "pgx", "alter table users drop constraint users_email_key;", nil},
+		{"drop default", "pgx", "alter table users alter column active drop default;", nil},
+		{"postgres column type", "pgx", "alter table users alter column email type text;", []string{"column-type"}},
+		{"mysql column type", "mysql", "alter table users modify email text not null;", []string{"column-type"}},
+		{"add column", "pgx", "alter table users add column name text;", nil},
+		{"postgres index", "pgx", "create index users_email_idx on users (email);", []string{"index-lock"}},
+		{"postgres concurrent index", "pgx", "create index concurrently users_email_idx on users (email);", nil},
+		{"index on new table", "pgx", "create table users (email text);\ncreate unique index users_email_idx on users (email);", nil},
+		{"mysql index", "mysql", "create index users_email_idx on users (email);", nil},
+		{"alter new table", "pgx", "create table users (email text);\nalter table users drop column email;", nil},
+	}
+
+	for _, tt := range tests {
+		issues := LintMigrationSQL(tt.dialect, tt.sql)
+		if len(issues) != len(tt.rules) {
+			t.Errorf("%s: expected %d issues, got %v", tt.name, len(tt.rules), issues)
+			continue
+		}
+		for i, issue := range issues {
+			if issue.Rule != tt.rules[i] {
+				t.Errorf("%s: expected rule %s, got %s", tt.name, tt.rules[i], issue.Rule)
+			}
+		}
+	}
+}
+
+func TestLintMigrationSQL_Line(t *testing.T) {
+	sql := "create table a (id int);\n\n-- remove the old table\ndrop table b;\n"
+
+	issues := LintMigrationSQL("pgx", sql)
+	if len(issues) != 1 || issues[0].Line != 4 || issues[0].Statement != "drop table b" {
+		t.Errorf("expected one issue on line 4, got %+v", issues)
+	}
+}
+
+func TestNavitas_PendingMigrations(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"1_create_users.up.sql":   "create table users (id integer primary key, email text);",
+		"1_create_users.down.sql": "drop table users;",
+		"2_drop_email.up.sql":     "alter table users drop column email;",
+		"2_drop_email.down.sql":   "alter table users add column email text;",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, "migrations", name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nav := &Navitas{RootPath: root, Config: Config{Database: DatabaseConfig{Type: "sqlite", Name: "lint.db"}}}
+	dsn := nav.BuildMigrationURL()
+	if err := nav.Steps(1, dsn); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := nav.PendingMigrations(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Version != 2 || pending[0].SQL != files["2_drop_email.up.sql"] {
+		t.Fatalf("expected migration 2 to be pending, got %+v", pending)
+	}
+
+	issues := nav.LintMigrations(pending)
+	if len(issues) != 1 || issues[0].Version != 2 || issues[0].Rule != "drop-column" {
+		t.Errorf("expected a drop-column issue in migration 2, got %v", issues)
+	}
+}
@@ -1,31 +1,126 @@
 package navitas
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/mysql"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+// migrationLockName identifies the database wide lock held while migrations run on boot
+const migrationLockName = "navitas_migrations"
+
+// migrationLockID is the postgres advisory lock key, the crc32 of migrationLockName
+const migrationLockID int64 = 0xc01c5c9f
+
+// migrationLockTimeout is how long a replica waits for another one to finish migrating on boot
+const migrationLockTimeout = 5 * time.Minute
+
+// MigrationStatus describes where the database is in the list of migration files
+type MigrationStatus struct {
+	Version    uint // 0 when no migration has been applied
+	Dirty      bool // the migration at Version failed part way through and must be fixed and forced
+	Migrations []Migration
+}
+
+// Migration is one versioned migration file and whether it has been applied
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+	Dirty   bool
+}
+
+// Pending returns the migrations that have not been applied yet
+func (s MigrationStatus) Pending() []Migration {
+	var pending []Migration
+	for _, m := range s.Migrations {
+		if !m.Applied {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// migrationSource returns the migration files, which are read from the fs.FS given to
+// WithMigrations, or from the database's migrations folder when there is none, merged with any
+// Go migrations
+func (n *Navitas) migrationSource() (source.Driver, error) {
+	fsys := n.migrationsFS
+	if fsys == nil {
+		fsys = os.DirFS(n.MigrationsPath())
+	}
+
+	files, err := iofs.New(fsys, ".")
+	if err != nil || len(n.goMigrations) == 0 {
+		return files, err
+	}
+
+	src, err := newGoMigrationSource(files, n.goMigrations)
+	if err != nil {
+		_ = files.Close()
+		return nil, err
+	}
+	return src, nil
+}
+
+// newMigrate creates a migration runner for dsn. With Go migrations registered and the database
+// open, it runs on the application's database instead, which dsn must describe.
+func (n *Navitas) newMigrate(dsn string) (*migrate.Migrate, error) {
+	src, err := n.migrationSource()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(n.goMigrations) == 0 {
+		m, err := migrate.NewWithSourceInstance("iofs", src, dsn)
+		if err != nil {
+			_ = src.Close()
+			return nil, err
+		}
+		return m, nil
+	}
+
+	drv, err := n.openGoMigrationDriver(dsn)
+	if err != nil {
+		_ = src.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "navitas", drv)
+	if err != nil {
+		_ = src.Close()
+		_ = drv.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
 func (n *Navitas) MigrateUp(dsn string) error {
-	m, err := migrate.New("file://"+n.RootPath+"/migrations", dsn)
+	m, err := n.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
 	defer m.Close()
 
 	if err := m.Up(); err != nil {
-		log.Println("Error running migration:", err)
 		return err
 	}
 	return nil
 }
 
 func (n *Navitas) MigrateDownAll(dsn string) error {
-	m, err := migrate.New("file://"+n.RootPath+"/migrations", dsn)
+	m, err := n.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -38,7 +133,7 @@ func (n *Navitas) MigrateDownAll(dsn string) error {
 }
 
 func (n *Navitas) Steps(val int, dsn string) error {
-	m, err := migrate.New("file://"+n.RootPath+"/migrations", dsn)
+	m, err := n.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
@@ -50,15 +145,193 @@ func (n *Navitas) Steps(val int, dsn string) error {
 	return nil
 }
 
-func (n *Navitas) MigrateForce(dsn string) error {
-	m, err := migrate.New("file://"+n.RootPath+"/migrations", dsn)
+// MigrateDown reverses the most recent steps migrations
+func (n *Navitas) MigrateDown(steps int, dsn string) error {
+	if steps < 1 {
+		return errors.New("migrate down needs at least one step")
+	}
+	return n.Steps(-steps, dsn)
+}
+
+// MigrateTo migrates up or down until the database is at version
+func (n *Navitas) MigrateTo(version uint, dsn string) error {
+	m, err := n.newMigrate(dsn)
 	if err != nil {
 		return err
 	}
 	defer m.Close()
 
-	if err := m.Force(-1); err != nil {
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	}
 	return nil
 }
+
+// MigrateForce records version as the current migration and clears the dirty flag, without
+// running anything. Use it once a failed migration has been fixed by hand. A version of -1
+// means no migration has been applied.
+func (n *Navitas) MigrateForce(version int, dsn string) error {
+	m, err := n.newMigrate(dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Force(version); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MigrationStatus lists every migration file, marking those that have been applied
+func (n *Navitas) MigrationStatus(dsn string) (*MigrationStatus, error) {
+	m, err := n.newMigrate(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	status := &MigrationStatus{}
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+	case err != nil:
+		return nil, err
+	default:
+		status.Version, status.Dirty = version, dirty
+	}
+
+	src, err := n.migrationSource()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	v, err := src.First()
+	for err == nil {
+		status.Migrations = append(status.Migrations, Migration{
+			Version: v,
+			Name:    migrationName(src, v),
+			Applied: status.Version != 0 && v <= status.Version && !(status.Dirty && v == status.Version),
+			Dirty:   status.Dirty && v == status.Version,
+		})
+		v, err = src.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// migrationName returns the identifier of a migration, which is the file name without the version
+// and extension
+func migrationName(src source.Driver, version uint) string {
+	r, name, err := src.ReadUp(version)
+	if err != nil {
+		r, name, err = src.ReadDown(version)
+		if err != nil {
+			return ""
+		}
+	}
+	_ = r.Close()
+	return name
+}
+
+// autoMigrate runs pending migrations on boot. A database wide lock is held while they run, so
+// when several replicas start at once only the first migrates and the rest wait for it to finish.
+// Migrations with destructive statements are refused unless they are allowed, as is migrating
+// with Go migrations in the migrations folder that have not been registered.
+func (n *Navitas) autoMigrate(ctx context.Context) error {
+	if err := n.checkGoMigrationsRegistered(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, migrationLockTimeout)
+	defer cancel()
+
+	unlock, err := n.lockMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer unlock()
+
+	dsn := n.BuildMigrationURL()
+	if err := n.checkPendingMigrations(dsn); err != nil {
+		return err
+	}
+
+	err = n.MigrateUp(dsn)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	return nil
+}
+
+// checkPendingMigrations lints the pending migrations, and refuses to run them if they contain
+// destructive statements, unless DATABASE_ALLOW_DESTRUCTIVE or WithDestructiveMigrations allows it
+func (n *Navitas) checkPendingMigrations(dsn string) error {
+	pending, err := n.PendingMigrations(dsn)
+	if err != nil {
+		return err
+	}
+
+	issues := n.LintMigrations(pending)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	if n.destructiveOK || n.databaseConfig().AllowDestructive {
+		for _, issue := range issues {
+			n.InfoLog.Println("Running destructive migration:", issue)
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, issue := range issues {
+		errs = append(errs, errors.New(issue.String()))
+	}
+	return fmt.Errorf("pending migrations contain destructive statements; review them with navitas migrate --dry-run, then set DATABASE_ALLOW_DESTRUCTIVE=true to run them: %w", errors.Join(errs...))
+}
+
+// lockMigrations takes an advisory lock on a dedicated connection, and returns a function that
+// releases it. sqlite databases are local files, so they are not locked.
+func (n *Navitas) lockMigrations(ctx context.Context) (func(), error) {
+	// a held connection would also leave a single connection pool with none to migrate on
+	if n.DB.DatabaseType == "sqlite" {
+		return func() {}, nil
+	}
+
+	conn, err := n.DB.Pool.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release := ""
+	switch n.DB.DatabaseType {
+	case "postgres", "postgresql", "pgx":
+		_, err = conn.ExecContext(ctx, "select pg_advisory_lock($1)", migrationLockID)
+		release = fmt.Sprintf("select pg_advisory_unlock(%d)", migrationLockID)
+
+	case "mysql", "mariadb":
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, "select get_lock(?, ?)", migrationLockName, int(migrationLockTimeout.Seconds())).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = errors.New("timed out waiting for another instance to finish migrating")
+		}
+		release = fmt.Sprintf("select release_lock('%s')", migrationLockName)
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		if release != "" {
+			_, _ = conn.ExecContext(context.Background(), release)
+		}
+		_ = conn.Close()
+	}, nil
+}
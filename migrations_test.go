@@ -0,0 +1,159 @@
+package navitas
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNavitas_MigrationStatus(t *testing.T) {
+	root := t.TempDir()
+	n := &Navitas{RootPath: root}
+
+	files := map[string]string{
+		"1_create_users.up.sql":   "create table users (id integer primary key);",
+		"1_create_users.down.sql": "drop table users;",
+		"2_create_posts.up.sql":   "create table posts (id integer primary key);",
+		"2_create_posts.down.sql": "drop table posts;",
+		"3_broken.up.sql":         "create table nope (",
+		"3_broken.down.sql":       "select 1;",
+	}
+	err := os.MkdirAll(filepath.Join(root, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, "migrations", name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dsn := "sqlite://" + filepath.Join(root, "test.db")
+
+	status, err := n.MigrationStatus(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 0 || len(status.Pending()) != 3 {
+		t.Errorf("expected three pending migrations, got %+v", status)
+	}
+
+	if err := n.MigrateTo(2, dsn); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = n.MigrationStatus(dsn)
+	if status.Version != 2 || len(status.Pending()) != 1 || status.Migrations[1].Name != "create_posts" {
+		t.Errorf("expected to be at version 2 with one pending migration, got %+v", status)
+	}
+
+	if err := n.MigrateDown(1, dsn); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = n.MigrationStatus(dsn)
+	if status.Version != 1 {
+		t.Error("expected down 1 to leave version 1, got", status.Version)
+	}
+
+	if err := n.MigrateUp(dsn); err == nil {
+		t.Fatal("expected broken migration to fail")
+	}
+	status, _ = n.MigrationStatus(dsn)
+	if !status.Dirty || status.Version != 3 || !status.Migrations[2].Dirty || status.Migrations[2].Applied {
+		t.Errorf("expected version 3 to be dirty, got %+v", status)
+	}
+
+	if err := n.MigrateForce(2, dsn); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = n.MigrationStatus(dsn)
+	if status.Dirty || status.Version != 2 {
+		t.Errorf("expected force to clear the dirty flag at version 2, got %+v", status)
+	}
+}
+
+func TestNewWithConfig_AutoMigrate(t *testing.T) {
+	migrations := fstest.MapFS{
+		"1_create_sessions.up.sql":   {Data: []byte("CREATE TABLE sessions (token TEXT PRIMARY KEY, data BLOB NOT NULL, expiry REAL NOT NULL);")},
+		"1_create_sessions.down.sql": {Data: []byte("DROP TABLE sessions;")},
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"DATABASE_TYPE":         "sqlite",
+		"DATABASE_AUTO_MIGRATE": "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithMigrations(migrations))
+	if err != nil {
+		t.Fatal("unexpected error booting with auto migrate:", err)
+	}
+	defer n.Shutdown(context.Background())
+
+	status, err := n.MigrationStatus(n.BuildMigrationURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 1 || len(status.Pending()) != 0 {
+		t.Errorf("expected embedded migrations to be applied on boot, got %+v", status)
+	}
+}
+
+func TestNewWithConfig_AutoMigrateRefusesDestructive(t *testing.T) {
+	migrations := fstest.MapFS{
+		"1_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);")},
+		"1_create_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"2_drop_name.up.sql":        {Data: []byte("ALTER TABLE widgets DROP COLUMN name;")},
+		"2_drop_name.down.sql":      {Data: []byte("ALTER TABLE widgets ADD COLUMN name TEXT;")},
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		opts    []Option
+		wantErr bool
+	}{
+		{"refused by default", nil, nil, true},
+		{"allowed by env", map[string]string{"DATABASE_ALLOW_DESTRUCTIVE": "true"}, nil, false},
+		{"allowed by option", nil, []Option{WithDestructiveMigrations()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"DATABASE_TYPE": "sqlite", "DATABASE_AUTO_MIGRATE": "true"}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			cfg, err := loadConfig(envFrom(env))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root := t.TempDir()
+			opts := append([]Option{WithRootPath(root), WithMigrations(migrations)}, tt.opts...)
+			n, err := NewWithConfig(*cfg, opts...)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "drop-column") {
+					t.Fatalf("expected the destructive migration to be refused, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error booting with an allowed destructive migration:", err)
+			}
+			defer n.Shutdown(context.Background())
+
+			status, err := n.MigrationStatus(n.BuildMigrationURL())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Version != 2 {
+				t.Errorf("expected every migration to be applied, got version %d", status.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package navitas
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Module is a self contained part of an application, such as a payments client or a search
+// index, that plugs into the same lifecycle as the built in cache, mailer and file systems.
+//
+// Register is called once the core services (config, database, cache, mail, scheduler) exist,
+// and is the place to read configuration, add scheduled jobs and health checks. Modules that
+// serve http routes add them by also implementing RouteModule, not from Register. Boot is called
+// after every module has been registered, so modules may depend on each other. Shutdown is called
+// in reverse order when the application stops, after the web server and scheduler have stopped
+// but before the database and cache connections are closed.
+type Module interface {
+	Register(n *Navitas) error
+	Boot(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// RouteModule is implemented by modules that serve http routes. Routes is called the first time
+// Handler is called, which ListenAndServe does as it starts serving and an application with its
+// own http.Server does once it has added its middleware to Routes, since chi refuses middleware
+// added to a mux that already has routes. An application that serves Routes directly never gets
+// module routes.
+type RouteModule interface {
+	Module
+	Routes(r chi.Router)
+}
+
+// AddModule adds m to the application. Modules added before New or NewWithConfig are registered
+// and booted in the order they were added; a module added afterwards is registered and booted
+// immediately, and only added if both succeed.
+func (n *Navitas) AddModule(m Module) error {
+	if !n.booted {
+		n.modules = append(n.modules, m)
+		return nil
+	}
+
+	if err := m.Register(n); err != nil {
+		return fmt.Errorf("register module %T: %w", m, err)
+	}
+	if err := m.Boot(context.Background()); err != nil {
+		return fmt.Errorf("boot module %T: %w", m, err)
+	}
+	n.modules = append(n.modules, m)
+	n.bootedModules = append(n.bootedModules, m)
+
+	if rm, ok := m.(RouteModule); ok && n.routesMounted {
+		rm.Routes(n.Routes)
+	}
+	return nil
+}
+
+// WithModules adds modules to an application created by NewWithConfig
+func WithModules(modules ...Module) Option {
+	return func(n *Navitas) {
+		n.modules = append(n.modules, modules...)
+	}
+}
+
+// bootModules registers every module, then boots them all. If one fails, the modules booted before
+// it are shut down by Shutdown, which init calls on failure.
+func (n *Navitas) bootModules(ctx context.Context) error {
+	for _, m := range n.modules {
+		if err := m.Register(n); err != nil {
+			return fmt.Errorf("register module %T: %w", m, err)
+		}
+	}
+
+	for _, m := range n.modules {
+		if err := m.Boot(ctx); err != nil {
+			return fmt.Errorf("boot module %T: %w", m, err)
+		}
+		n.bootedModules = append(n.bootedModules, m)
+	}
+
+	n.booted = true
+	return nil
+}
+
+// mountModuleRoutes adds the routes of every booted RouteModule to Routes. It runs once, from the
+// first call to Handler; modules added after that mount their routes as they are added.
+func (n *Navitas) mountModuleRoutes() {
+	n.routesOnce.Do(func() {
+		for _, m := range n.bootedModules {
+			if rm, ok := m.(RouteModule); ok {
+				rm.Routes(n.Routes)
+			}
+		}
+		n.routesMounted = true
+	})
+}
+
+// shutdownModules shuts the modules that booted down in reverse order, collecting every error
+func (n *Navitas) shutdownModules(ctx context.Context) []error {
+	var errs []error
+	for i := len(n.bootedModules) - 1; i >= 0; i-- {
+		m := n.bootedModules[i]
+		if err := m.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("module %T: %w", m, err))
+		}
+	}
+	return errs
+}
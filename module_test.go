@@ -0,0 +1,226 @@
+package navitas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/robfig/cron/v3"
+)
+
+type testModule struct {
+	name   string
+	events *[]string
+}
+
+func (m *testModule) Register(n *Navitas) error {
+	*m.events = append(*m.events, "register "+m.name)
+	return nil
+}
+
+func (m *testModule) Routes(r chi.Router) {
+	r.Get("/"+m.name, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (m *testModule) Boot(ctx context.Context) error {
+	*m.events = append(*m.events, "boot "+m.name)
+	return nil
+}
+
+func (m *testModule) Shutdown(ctx context.Context) error {
+	*m.events = append(*m.events, "shutdown "+m.name)
+	return nil
+}
+
+func TestNavitas_Modules(t *testing.T) {
+	var events []string
+
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg,
+		WithRootPath(t.TempDir()),
+		WithModules(&testModule{"payments", &events}, &testModule{"search", &events}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the application adds its middleware after init, before module routes are mounted
+	n.Routes.Use(func(next http.Handler) http.Handler {
+		return next
+	})
+
+	// as an application running its own http.Server would
+	server := httptest.NewServer(n.Handler())
+	resp, err := http.Get(server.URL + "/search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	server.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Error("expected module route to be mounted, got status", resp.StatusCode)
+	}
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"register payments", "register search",
+		"boot payments", "boot search",
+		"shutdown search", "shutdown payments",
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v and got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %q and got %q", i, expected[i], events[i])
+		}
+	}
+}
+
+// failingModule is a testModule whose Register or Boot fails
+type failingModule struct {
+	testModule
+	failRegister bool
+	failBoot     bool
+}
+
+func (m *failingModule) Register(n *Navitas) error {
+	if m.failRegister {
+		return errors.New("register failed")
+	}
+	return m.testModule.Register(n)
+}
+
+func (m *failingModule) Boot(ctx context.Context) error {
+	if m.failBoot {
+		*m.events = append(*m.events, "boot failed "+m.name)
+		return errors.New("boot failed")
+	}
+	return m.testModule.Boot(ctx)
+}
+
+func TestNavitas_FailedBootShutsDownBootedModules(t *testing.T) {
+	var events []string
+
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewWithConfig(*cfg,
+		WithRootPath(t.TempDir()),
+		WithModules(
+			&testModule{"payments", &events},
+			&testModule{"search", &events},
+			&failingModule{testModule: testModule{"mailing", &events}, failBoot: true},
+			&testModule{"reports", &events},
+		),
+	)
+	if err == nil {
+		t.Fatal("expected an error when a module fails to boot")
+	}
+
+	expected := []string{
+		"register payments", "register search", "register mailing", "register reports",
+		"boot payments", "boot search", "boot failed mailing",
+		"shutdown search", "shutdown payments",
+	}
+	if strings.Join(events, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected events %v and got %v", expected, events)
+	}
+}
+
+func TestNavitas_AddModuleAfterBoot(t *testing.T) {
+	var events []string
+
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.AddModule(&failingModule{testModule: testModule{"broken", &events}, failRegister: true}); err == nil {
+		t.Error("expected an error adding a module that fails to register")
+	}
+	if err := n.AddModule(&failingModule{testModule: testModule{"flaky", &events}, failBoot: true}); err == nil {
+		t.Error("expected an error adding a module that fails to boot")
+	}
+	if err := n.AddModule(&testModule{"search", &events}); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.modules) != 1 {
+		t.Errorf("expected only the module that booted to be added, got %d modules", len(n.modules))
+	}
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"register flaky", "boot failed flaky", "register search", "boot search", "shutdown search"}
+	if strings.Join(events, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected events %v and got %v", expected, events)
+	}
+}
+
+// tickSchedule runs a scheduled job every few milliseconds, rather than cron's one second minimum
+type tickSchedule struct{}
+
+func (tickSchedule) Next(t time.Time) time.Time {
+	return t.Add(5 * time.Millisecond)
+}
+
+// schedulingModule adds a scheduled job when it is registered, and closes ran once it has run
+type schedulingModule struct {
+	testModule
+	ran  chan struct{}
+	once sync.Once
+}
+
+func (m *schedulingModule) Register(n *Navitas) error {
+	n.Scheduler.Schedule(tickSchedule{}, cron.FuncJob(func() {
+		m.once.Do(func() { close(m.ran) })
+	}))
+	return m.testModule.Register(n)
+}
+
+func TestNavitas_ModuleScheduledJobsRun(t *testing.T) {
+	var events []string
+
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &schedulingModule{testModule: testModule{"reports", &events}, ran: make(chan struct{})}
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithModules(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	select {
+	case <-m.ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the job the module scheduled to run")
+	}
+}
@@ -1,14 +1,24 @@
 package navitas
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/rpc"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/CloudyKit/jet/v6"
@@ -18,48 +28,79 @@ import (
 	"github.com/bmozi/navitas/filesystems/s3filesystem"
 	"github.com/bmozi/navitas/filesystems/sftpfilesystem"
 	"github.com/bmozi/navitas/filesystems/webdavfilesystem"
+	"github.com/bmozi/navitas/logger"
 	"github.com/bmozi/navitas/mailer"
+	"github.com/bmozi/navitas/metrics"
 	"github.com/bmozi/navitas/render"
 	"github.com/bmozi/navitas/session"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/go-chi/chi/v5"
 	"github.com/gomodule/redigo/redis"
-	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
 )
 
 const version = "1.0.0"
 
-var myRedisCache *cache.RedisCache
-var myBadgerCache *cache.BadgerCache
-var redisPool *redis.Pool
-var badgerConn *badger.DB
-
 // Navitas is the overall type for the Navitas package. Members that are exported in this type
 // are available to any application that uses it.
 type Navitas struct {
-	AppName       string
-	Debug         bool
-	Version       string
-	ErrorLog      *log.Logger
-	InfoLog       *log.Logger
-	RootPath      string
-	Routes        *chi.Mux
-	Render        *render.Render
-	Session       *scs.SessionManager
-	DB            Database
-	JetViews      *jet.Set
-	config        config
-	EncryptionKey string
-	Cache         cache.Cache
-	Scheduler     *cron.Cron
-	Mail          mailer.Mail
-	Server        Server
-	FileSystems   map[string]interface{}
-	S3            s3filesystem.S3
-	SFTP          sftpfilesystem.SFTP
-	WebDAV        webdavfilesystem.WebDAV
-	Minio         miniofilesystem.Minio
+	AppName        string
+	Environment    string
+	Debug          bool
+	Version        string
+	ErrorLog       *log.Logger
+	InfoLog        *log.Logger
+	Logger         *slog.Logger
+	RootPath       string
+	Routes         *chi.Mux // serve Handler, which wraps Routes, rather than Routes itself
+	Render         *render.Render
+	Session        *scs.SessionManager
+	DB             Database
+	DBs            map[string]Database // named connections from Config.Databases
+	JetViews       *jet.Set
+	Config         Config
+	EncryptionKey  string
+	Cache          cache.Cache
+	Metrics        *metrics.Registry
+	Scheduler      *cron.Cron
+	Mail           mailer.Mail
+	Server         Server
+	FileSystems    map[string]interface{}
+	S3             s3filesystem.S3
+	SFTP           sftpfilesystem.SFTP
+	WebDAV         webdavfilesystem.WebDAV
+	Minio          miniofilesystem.Minio
+	logFile        *logger.RotatingFile
+	redisPool      *redis.Pool
+	badgerConn     *badger.DB
+	serverMu       sync.Mutex // guards the servers, rpcListener and shuttingDown
+	server         *http.Server
+	redirectServer *http.Server
+	metricsServer  *http.Server
+	tlsConfig      *tls.Config
+	rpcListener    net.Listener
+	shuttingDown   bool
+	maintenance    atomic.Bool
+	healthMu       sync.RWMutex
+	healthChecks   map[string]HealthCheck
+	httpRequests   *metrics.Counter
+	httpDuration   *metrics.Histogram
+	cronRuns       uint64
+	modules        []Module
+	bootedModules  []Module // the modules whose Boot succeeded, in the order they booted
+	routesOnce     sync.Once
+	routesMounted  bool // whether the RouteModule routes have been added to Routes
+	migrationsFS   fs.FS
+	dbMigrationsFS map[string]fs.FS
+	goMigrations   map[uint]GoMigration
+	dbGoMigrations map[string]map[uint]GoMigration
+	destructiveOK  bool
+	seeders        []Seeder
+	booted         bool
+	mailStop       chan struct{}
+	mailDone       chan struct{}
+	shutdownOnce   sync.Once
+	shutdownErr    error
 }
 
 type Server struct {
@@ -69,21 +110,6 @@ type Server struct {
 	URL        string
 }
 
-type config struct {
-	port        string
-	renderer    string
-	cookie      cookieConfig
-	sessionType string
-	database    databaseConfig
-	redis       redisConfig
-	uploads     uploadConfig
-}
-
-type uploadConfig struct {
-	allowedMimeTypes []string
-	maxUploadSize    int64
-}
-
 // New reads the .env file, creates our application config, populates the Navitas type with settings
 // based on .env values, and creates necessary folders and files if they don't exist
 func (n *Navitas) New(rootPath string) error {
@@ -102,48 +128,128 @@ func (n *Navitas) New(rootPath string) error {
 		return err
 	}
 
-	// read .env
-	err = godotenv.Load(rootPath + "/.env")
+	// read .env, .env.<environment> and .env.local
+	_, err = LoadEnvFiles(rootPath)
 	if err != nil {
 		return err
 	}
 
+	// read and validate the configuration once; every bad or missing key is reported together
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	return n.init(*cfg, WithRootPath(rootPath))
+}
+
+// NewWithConfig creates a Navitas instance from an explicit configuration. Unlike New, it does
+// not read a .env file, create the application folders or exit the process; any failure is
+// returned as an error.
+//
+// Files are read and written relative to the root path, which is the current directory unless
+// WithRootPath is given: the badger cache in tmp/badger, a relative LOG_FILE in logs and the
+// maintenance flag in tmp/maintenance all land there. Pass WithRootPath to keep them elsewhere.
+func NewWithConfig(cfg Config, opts ...Option) (*Navitas, error) {
+	n := &Navitas{}
+	if err := n.init(cfg, opts...); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// init populates the Navitas type from cfg, connecting to the database, cache and mail
+// services it describes. Connections opened before a failure are closed again.
+func (n *Navitas) init(cfg Config, opts ...Option) (err error) {
+	n.Config = cfg
+	n.RootPath = "."
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = n.Shutdown(context.Background())
+		}
+	}()
+
 	// create loggers
-	infoLog, errorLog := n.startLoggers()
-	n.InfoLog = infoLog
-	n.ErrorLog = errorLog
+	n.startLoggers()
 
-	n.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
+	n.AppName = n.Config.AppName
+	n.Environment = n.Config.Environment
+	n.Debug = n.Config.Debug
 	n.Version = version
-	n.RootPath = rootPath
-	n.Routes = n.routes().(*chi.Mux)
+	n.maintenance.Store(n.maintenanceFileExists())
+
+	// load the certificate now, so a bad path or key fails at boot rather than at listen time
+	if n.Config.TLS.CertFile != "" {
+		tlsConfig, err := n.loadTLSConfig()
+		if err != nil {
+			return err
+		}
+		n.tlsConfig = tlsConfig
+	}
+
+	if n.Config.Metrics.Enabled {
+		n.startMetrics()
+	}
 
 	// connect to database
-	if os.Getenv("DATABASE_TYPE") != "" {
-		db, err := n.OpenDB(os.Getenv("DATABASE_TYPE"), n.BuildDSN())
+	if n.DB.Pool == nil && n.Config.Database.Type != "" {
+		db, err := n.openDatabase(n.Config.Database)
 		if err != nil {
-			errorLog.Println(err)
-			os.Exit(1)
+			return err
 		}
-		n.DB = Database{
-			DatabaseType: os.Getenv("DATABASE_TYPE"),
-			Pool:         db,
+		n.DB = db
+	}
+
+	err = n.openNamedDatabases()
+	if err != nil {
+		return err
+	}
+
+	// migrate before anything else touches the database, since the session table may be pending
+	if n.Config.Database.AutoMigrate && n.DB.Pool != nil {
+		if err := n.autoMigrate(context.Background()); err != nil {
+			return err
+		}
+	}
+	for _, name := range n.databaseNames() {
+		if !n.Config.Databases[name].AutoMigrate {
+			continue
+		}
+		conn, err := n.Connection(name)
+		if err != nil {
+			return err
+		}
+		if err := conn.autoMigrate(context.Background()); err != nil {
+			return fmt.Errorf("database %s: %w", name, err)
 		}
 	}
 
 	scheduler := cron.New()
+	if n.Metrics != nil {
+		scheduler = cron.New(cron.WithChain(n.countCronRuns))
+	}
 	n.Scheduler = scheduler
 
-	if os.Getenv("CACHE") == "redis" || os.Getenv("SESSION_TYPE") == "redis" {
-		myRedisCache = n.createClientRedisCache()
-		n.Cache = myRedisCache
-		redisPool = myRedisCache.Conn
+	// the redis pool is shared by the cache and sessions, but only CACHE=redis makes it the cache
+	if n.Config.Cache == "redis" || n.Config.SessionType == "redis" {
+		n.redisPool = n.createRedisPool()
+	}
+	if n.Cache == nil && n.Config.Cache == "redis" {
+		n.Cache = n.createClientRedisCache()
 	}
 
-	if os.Getenv("CACHE") == "badger" {
-		myBadgerCache = n.createClientBadgerCache()
+	if n.Cache == nil && n.Config.Cache == "badger" {
+		myBadgerCache, err := n.createClientBadgerCache()
+		if err != nil {
+			return err
+		}
 		n.Cache = myBadgerCache
-		badgerConn = myBadgerCache.Conn
+		n.badgerConn = myBadgerCache.Conn
 
 		_, err = n.Scheduler.AddFunc("@daily", func() {
 			_ = myBadgerCache.Conn.RunValueLogGC(0.7)
@@ -153,94 +259,76 @@ func (n *Navitas) New(rootPath string) error {
 		}
 	}
 
-	// file uploads
-	exploded := strings.Split(os.Getenv("ALLOWED_FILETYPES"), ",")
-	var mimeTypes []string
-	for _, m := range exploded {
-		mimeTypes = append(mimeTypes, m)
-	}
-
-	var maxUploadSize int64
-	if max, err := strconv.Atoi(os.Getenv("MAX_UPLOAD_SIZE")); err != nil {
-		maxUploadSize = 10 << 20
-	} else {
-		maxUploadSize = int64(max)
-	}
-
-	n.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"),
-		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSISTS"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
-		},
-		sessionType: os.Getenv("SESSION_TYPE"),
-		database: databaseConfig{
-			database: os.Getenv("DATABASE_TYPE"),
-			dsn:      n.BuildDSN(),
-		},
-		redis: redisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
-		uploads: uploadConfig{
-			maxUploadSize:    maxUploadSize,
-			allowedMimeTypes: mimeTypes,
-		},
-	}
-
-	secure := true
-	if strings.ToLower(os.Getenv("SECURE")) == "false" {
-		secure = false
+	// the in-memory cache is the default, so there is always a working cache
+	if n.Cache == nil {
+		n.Cache = n.createClientMemoryCache()
 	}
 
 	n.Server = Server{
-		ServerName: os.Getenv("SERVER_NAME"),
-		Port:       os.Getenv("PORT"),
-		Secure:     secure,
-		URL:        os.Getenv("APP_URL"),
+		ServerName: n.Config.ServerName,
+		Port:       n.Config.Port,
+		Secure:     n.Config.Secure,
+		URL:        n.Config.AppURL,
 	}
 
 	// create session
 	sess := session.Session{
-		CookieLifetime: n.config.cookie.lifetime,
-		CookiePersist:  n.config.cookie.persist,
-		CookieName:     n.config.cookie.name,
-		SessionType:    n.config.sessionType,
-		CookieDomain:   n.config.cookie.domain,
+		CookieLifetime: strconv.Itoa(n.Config.Cookie.Lifetime),
+		CookiePersist:  strconv.FormatBool(n.Config.Cookie.Persist),
+		CookieName:     n.Config.Cookie.Name,
+		SessionType:    n.Config.SessionType,
+		CookieDomain:   n.Config.Cookie.Domain,
+		CookieSecure:   strconv.FormatBool(n.Config.Cookie.Secure),
 	}
 
-	switch n.config.sessionType {
+	switch n.Config.SessionType {
 	case "redis":
-		sess.RedisPool = myRedisCache.Conn
-	case "mysql", "postgres", "mariadb", "postgresql":
+		sess.RedisPool = n.redisPool
+	case "mysql", "postgres", "mariadb", "postgresql", "sqlite":
 		sess.DBPool = n.DB.Pool
 	}
 
 	n.Session = sess.InitSession()
-	n.EncryptionKey = os.Getenv("KEY")
+	n.EncryptionKey = n.Config.EncryptionKey
+
+	// routes are created once the session exists, since the middleware chain captures it
+	n.Routes = n.routes().(*chi.Mux)
 
 	if n.Debug {
 		var views = jet.NewSet(
-			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath)),
+			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", n.RootPath)),
 			jet.InDevelopmentMode(),
 		)
 		n.JetViews = views
 	} else {
 		var views = jet.NewSet(
-			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", rootPath)),
+			jet.NewOSFileSystemLoader(fmt.Sprintf("%s/views", n.RootPath)),
 		)
 		n.JetViews = views
 	}
 
 	n.createRenderer()
 	n.FileSystems = n.createFileSystems()
-	go n.Mail.ListenForMail()
+	n.Mail = n.createMailer()
+	n.mailStop = make(chan struct{})
+	n.mailDone = make(chan struct{})
+	go func() {
+		defer close(n.mailDone)
+		n.Mail.ListenForMailUntil(n.mailStop)
+	}()
+
+	n.registerHealthChecks()
+
+	if n.Metrics != nil {
+		n.registerMetrics()
+	}
 
+	if err := n.bootModules(context.Background()); err != nil {
+		return err
+	}
+
+	// start the scheduler once the modules have added their jobs; Shutdown stops it
+	n.Scheduler.Start()
 	return nil
 }
 
@@ -256,32 +344,211 @@ func (n *Navitas) Init(p initPaths) error {
 	return nil
 }
 
-// ListenAndServe starts the web server
-func (n *Navitas) ListenAndServe() {
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%s", os.Getenv("PORT")),
+// ListenAndServe starts the web server, and blocks until it is stopped. If a TLS certificate is
+// configured the server speaks HTTPS, optionally with a second listener that redirects plain
+// HTTP requests to it. With METRICS_PORT set, metrics are served on a listener of their own. On
+// SIGINT or SIGTERM the server is shut down gracefully, allowing in-flight requests up to
+// Config.ShutdownTimeout to complete before the scheduler, mail queue and connections are closed.
+func (n *Navitas) ListenAndServe() error {
+	n.serverMu.Lock()
+	if n.shuttingDown {
+		n.serverMu.Unlock()
+		return http.ErrServerClosed
+	}
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%s", n.Config.Port),
 		ErrorLog:     n.ErrorLog,
-		Handler:      n.Routes,
+		Handler:      n.Handler(),
 		IdleTimeout:  30 * time.Second,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 600 * time.Second,
+		TLSConfig:    n.tlsConfig,
+	}
+	n.server = server
+
+	if n.tlsConfig != nil && n.Config.TLS.RedirectPort != "" {
+		redirectServer := &http.Server{
+			Addr:         fmt.Sprintf(":%s", n.Config.TLS.RedirectPort),
+			ErrorLog:     n.ErrorLog,
+			Handler:      n.redirectToHTTPS(),
+			IdleTimeout:  30 * time.Second,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		n.redirectServer = redirectServer
+
+		go func() {
+			n.InfoLog.Printf("Redirecting HTTP on port %s to HTTPS", n.Config.TLS.RedirectPort)
+			err := redirectServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				n.ErrorLog.Println("http redirect server:", err)
+			}
+		}()
+	}
+
+	if n.Metrics != nil && n.Config.Metrics.Port != "" {
+		mux := http.NewServeMux()
+		mux.Handle(n.Config.Metrics.Path, n.metricsHandler())
+		metricsServer := &http.Server{
+			Addr:         fmt.Sprintf(":%s", n.Config.Metrics.Port),
+			ErrorLog:     n.ErrorLog,
+			Handler:      mux,
+			IdleTimeout:  30 * time.Second,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		n.metricsServer = metricsServer
+
+		go func() {
+			n.InfoLog.Printf("Serving metrics on port %s", n.Config.Metrics.Port)
+			err := metricsServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				n.ErrorLog.Println("metrics server:", err)
+			}
+		}()
 	}
 
-	if n.DB.Pool != nil {
-		defer n.DB.Pool.Close()
+	if err := n.listenRPC(); err != nil {
+		n.ErrorLog.Println("rpc server:", err)
 	}
+	n.serverMu.Unlock()
 
-	if redisPool != nil {
-		defer redisPool.Close()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			n.InfoLog.Printf("Listening for HTTPS on port %s", n.Config.Port)
+			// the certificate is already loaded into TLSConfig
+			serveErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+		n.InfoLog.Printf("Listening on port %s", n.Config.Port)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			// Shutdown was called programmatically
+			return nil
+		}
+		n.ErrorLog.Println(err)
+		_ = n.Shutdown(context.Background())
+		return err
+
+	case sig := <-quit:
+		n.InfoLog.Printf("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), n.shutdownTimeout())
+		defer cancel()
+		return n.Shutdown(ctx)
 	}
+}
 
-	if badgerConn != nil {
-		defer badgerConn.Close()
+// shutdownTimeout returns Config.ShutdownTimeout, or 30 seconds if it is not set, as it is not in
+// a Config built by hand for NewWithConfig
+func (n *Navitas) shutdownTimeout() time.Duration {
+	if n.Config.ShutdownTimeout <= 0 {
+		return 30 * time.Second
 	}
+	return n.Config.ShutdownTimeout
+}
+
+// Shutdown gracefully stops the application: the web server stops accepting connections and
+// waits for in-flight requests, the scheduler is stopped, modules are shut down in reverse order,
+// queued mail is sent, and finally the database, redis pool and badger database are closed, in
+// that order. If ctx expires before the server or mail queue have drained, the remaining
+// resources are still closed and the context error is returned. It is safe to call more than once,
+// and ListenAndServe returns http.ErrServerClosed once it has been called.
+func (n *Navitas) Shutdown(ctx context.Context) error {
+	n.shutdownOnce.Do(func() {
+		var errs []error
+
+		n.serverMu.Lock()
+		n.shuttingDown = true
+		server, redirectServer, rpcListener := n.server, n.redirectServer, n.rpcListener
+		metricsServer := n.metricsServer
+		n.serverMu.Unlock()
+
+		if rpcListener != nil {
+			_ = rpcListener.Close()
+		}
+
+		if redirectServer != nil {
+			if err := redirectServer.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("http redirect server: %w", err))
+			}
+		}
+
+		if metricsServer != nil {
+			if err := metricsServer.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("metrics server: %w", err))
+			}
+		}
+
+		if server != nil {
+			if err := server.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("http server: %w", err))
+			}
+		}
+
+		if n.Scheduler != nil {
+			select {
+			case <-n.Scheduler.Stop().Done():
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("scheduler: %w", ctx.Err()))
+			}
+		}
+
+		errs = append(errs, n.shutdownModules(ctx)...)
+
+		if n.mailDone != nil {
+			close(n.mailStop)
+			select {
+			case <-n.mailDone:
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("mail queue: %w", ctx.Err()))
+			}
+		}
 
-	n.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
-	err := srv.ListenAndServe()
-	n.ErrorLog.Fatal(err)
+		if err := n.DB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("database: %w", err))
+		}
+		for name, db := range n.DBs {
+			if err := db.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("database %s: %w", name, err))
+			}
+		}
+
+		if n.redisPool != nil {
+			if err := n.redisPool.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("redis: %w", err))
+			}
+		}
+
+		if c, ok := n.Cache.(*cache.MemoryCache); ok {
+			_ = c.Close()
+		}
+
+		if n.badgerConn != nil {
+			if err := n.badgerConn.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("badger: %w", err))
+			}
+		}
+
+		if n.logFile != nil {
+			if err := n.logFile.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("log file: %w", err))
+			}
+		}
+
+		n.shutdownErr = errors.Join(errs...)
+	})
+
+	return n.shutdownErr
 }
 
 func (n *Navitas) checkDotEnv(path string) error {
@@ -292,60 +559,99 @@ func (n *Navitas) checkDotEnv(path string) error {
 	return nil
 }
 
-func (n *Navitas) startLoggers() (*log.Logger, *log.Logger) {
-	var infoLog *log.Logger
-	var errorLog *log.Logger
+// logFileName returns LOG_FILE as given if it is absolute, and in RootPath/logs otherwise
+func (n *Navitas) logFileName() string {
+	if filepath.IsAbs(n.Config.Log.File) {
+		return n.Config.Log.File
+	}
+	return filepath.Join(n.RootPath, "logs", n.Config.Log.File)
+}
 
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+// startLoggers creates the structured logger, writing to stdout or to a rotating file, and the
+// info and error loggers, which write through it. Loggers supplied as options are left alone.
+func (n *Navitas) startLoggers() {
+	level, err := logger.ParseLevel(n.Config.Log.Level)
+	if err != nil {
+		level = slog.LevelInfo
+	}
 
-	return infoLog, errorLog
+	if n.Logger == nil {
+		var out io.Writer = os.Stdout
+		if n.Config.Log.File != "" {
+			n.logFile = &logger.RotatingFile{
+				Filename: n.logFileName(),
+				MaxSize:  int64(n.Config.Log.MaxSize) << 20,
+				MaxAge:   time.Duration(n.Config.Log.MaxAge) * 24 * time.Hour,
+			}
+			out = n.logFile
+		}
+		n.Logger = logger.New(out, level, n.Config.Log.Format)
+	}
+
+	if n.InfoLog == nil {
+		n.InfoLog = slog.NewLogLogger(n.Logger.Handler(), slog.LevelInfo)
+	}
+
+	if n.ErrorLog == nil {
+		n.ErrorLog = slog.NewLogLogger(n.Logger.Handler(), slog.LevelError)
+	}
 }
 
 func (n *Navitas) createRenderer() {
 	myRenderer := render.Render{
-		Renderer: n.config.renderer,
-		RootPath: n.RootPath,
-		Port:     n.config.port,
-		JetViews: n.JetViews,
+		Renderer:   n.Config.Renderer,
+		RootPath:   n.RootPath,
+		Secure:     n.Config.Secure,
+		Port:       n.Config.Port,
+		ServerName: n.Config.ServerName,
+		JetViews:   n.JetViews,
+		Session:    n.Session,
 	}
 	n.Render = &myRenderer
 }
 
 func (n *Navitas) createMailer() mailer.Mail {
-	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
 	m := mailer.Mail{
-		Domain:      os.Getenv("MAIL_DOMAIN"),
+		Domain:      n.Config.Mail.Domain,
 		Templates:   n.RootPath + "/mail",
-		Host:        os.Getenv("SMTP_HOST"),
-		Port:        port,
-		Username:    os.Getenv("SMTP_USERNAME"),
-		Password:    os.Getenv("SMTP_PASSWORD"),
-		Encryption:  os.Getenv("SMTP_ENCRYPTION"),
-		FromName:    os.Getenv("FROM_NAME"),
-		FromAddress: os.Getenv("FROM_ADDRESS"),
+		Host:        n.Config.Mail.Host,
+		Port:        n.Config.Mail.Port,
+		Username:    n.Config.Mail.Username,
+		Password:    n.Config.Mail.Password,
+		Encryption:  n.Config.Mail.Encryption,
+		FromName:    n.Config.Mail.FromName,
+		FromAddress: n.Config.Mail.FromAddress,
 		Jobs:        make(chan mailer.Message, 20),
 		Results:     make(chan mailer.Result, 20),
-		API:         os.Getenv("MAILER_API"),
-		APIKey:      os.Getenv("MAILER_KEY"),
-		APIUrl:      os.Getenv("MAILER_URL"),
+		API:         n.Config.Mail.API,
+		APIKey:      n.Config.Mail.APIKey,
+		APIUrl:      n.Config.Mail.APIURL,
 	}
 	return m
 }
 
 func (n *Navitas) createClientRedisCache() *cache.RedisCache {
 	cacheClient := cache.RedisCache{
-		Conn:   n.createRedisPool(),
-		Prefix: n.config.redis.prefix,
+		Conn:   n.redisPool,
+		Prefix: n.Config.Redis.Prefix,
 	}
 	return &cacheClient
 }
 
-func (n *Navitas) createClientBadgerCache() *cache.BadgerCache {
+func (n *Navitas) createClientBadgerCache() (*cache.BadgerCache, error) {
+	conn, err := n.createBadgerConn()
+	if err != nil {
+		return nil, err
+	}
 	cacheClient := cache.BadgerCache{
-		Conn: n.createBadgerConn(),
+		Conn: conn,
 	}
-	return &cacheClient
+	return &cacheClient, nil
+}
+
+func (n *Navitas) createClientMemoryCache() *cache.MemoryCache {
+	cfg := n.Config.MemoryCache
+	return cache.NewMemoryCache(cfg.MaxEntries, cfg.MaxBytes, cfg.CleanupInterval)
 }
 
 func (n *Navitas) createRedisPool() *redis.Pool {
@@ -355,8 +661,8 @@ func (n *Navitas) createRedisPool() *redis.Pool {
 		IdleTimeout: 240 * time.Second,
 		Dial: func() (redis.Conn, error) {
 			return redis.Dial("tcp",
-				n.config.redis.host,
-				redis.DialPassword(n.config.redis.password))
+				n.Config.Redis.Host,
+				redis.DialPassword(n.Config.Redis.Password))
 		},
 
 		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
@@ -366,90 +672,65 @@ func (n *Navitas) createRedisPool() *redis.Pool {
 	}
 }
 
-func (n *Navitas) createBadgerConn() *badger.DB {
-	db, err := badger.Open(badger.DefaultOptions(n.RootPath + "/tmp/badger"))
-	if err != nil {
-		return nil
-	}
-	return db
+func (n *Navitas) createBadgerConn() (*badger.DB, error) {
+	return badger.Open(badger.DefaultOptions(n.RootPath + "/tmp/badger"))
 }
 
 // BuildDSN builds the datasource name for our database, and returns it as a string
 func (n *Navitas) BuildDSN() string {
-	var dsn string
-
-	switch os.Getenv("DATABASE_TYPE") {
-	case "postgres", "postgresql":
-		dsn = fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=%s timezone=UTC connect_timeout=5",
-			os.Getenv("DATABASE_HOST"),
-			os.Getenv("DATABASE_PORT"),
-			os.Getenv("DATABASE_USER"),
-			os.Getenv("DATABASE_NAME"),
-			os.Getenv("DATABASE_SSL_MODE"))
-
-		// we check to see if a database passsword has been supplied, since including "password=" with nothing
-		// after it sometimes causes postgres to fail to allow a connection.
-		if os.Getenv("DATABASE_PASS") != "" {
-			dsn = fmt.Sprintf("%s password=%s", dsn, os.Getenv("DATABASE_PASS"))
-		}
+	return n.databaseConfig().DSN()
+}
 
-	default:
+// BuildMigrationURL builds the url the migration runner uses to reach our database
+func (n *Navitas) BuildMigrationURL() string {
+	return n.databaseConfig().MigrationURL()
+}
 
-	}
+// MigrationsPath returns the folder the database's migration files are read from
+func (n *Navitas) MigrationsPath() string {
+	return n.databaseConfig().Migrations
+}
+
+// databaseConfig returns the database settings with paths resolved against RootPath
+func (n *Navitas) databaseConfig() DatabaseConfig {
+	return n.resolveDatabase(n.Config.Database)
+}
 
-	return dsn
+// resolveDatabase resolves a relative sqlite file and migrations folder against RootPath
+func (n *Navitas) resolveDatabase(db DatabaseConfig) DatabaseConfig {
+	if db.Type == "sqlite" && db.Name != ":memory:" {
+		db.Name = n.resolvePath(db.Name)
+	}
+	if db.Migrations == "" {
+		db.Migrations = "migrations"
+	}
+	db.Migrations = n.resolvePath(db.Migrations)
+	return db
 }
 
 func (n *Navitas) createFileSystems() map[string]interface{} {
 	fileSystems := make(map[string]interface{})
 
-	if os.Getenv("S3_KEY") != "" {
-		s3 := s3filesystem.S3{
-			Key:      os.Getenv("S3_KEY"),
-			Secret:   os.Getenv("S3_SECRET"),
-			Region:   os.Getenv("S3_REGION"),
-			Endpoint: os.Getenv("S3_ENDPOINT"),
-			Bucket:   os.Getenv("S3_BUCKET"),
-		}
+	if n.Config.S3.Key != "" {
+		s3 := n.Config.S3
 		fileSystems["S3"] = s3
 		n.S3 = s3
 	}
 
-	if os.Getenv("MINIO_SECRET") != "" {
-		useSSL := false
-		if strings.ToLower(os.Getenv("MINIO_USESSL")) == "true" {
-			useSSL = true
-		}
-
-		minio := miniofilesystem.Minio{
-			Endpoint: os.Getenv("MINIO_ENDPOINT"),
-			Key:      os.Getenv("MINIO_KEY"),
-			Secret:   os.Getenv("MINIO_SECRET"),
-			UseSSL:   useSSL,
-			Region:   os.Getenv("MINIO_REGION"),
-			Bucket:   os.Getenv("MINIO_BUCKET"),
-		}
+	if n.Config.Minio.Secret != "" {
+		minio := n.Config.Minio
 		fileSystems["MINIO"] = minio
 		n.Minio = minio
 	}
 
-	if os.Getenv("SFTP_HOST") != "" {
-		sftp := sftpfilesystem.SFTP{
-			Host: os.Getenv("SFTP_HOST"),
-			User: os.Getenv("SFTP_USER"),
-			Pass: os.Getenv("SFTP_PASS"),
-			Port: os.Getenv("SFTP_PORT"),
-		}
+	if n.Config.SFTP.Host != "" {
+		sftp := n.Config.SFTP
 		fileSystems["SFTP"] = sftp
 		n.SFTP = sftp
 	}
 
-	if os.Getenv("WEBDAV_HOST") != "" {
-		webDav := webdavfilesystem.WebDAV{
-			Host: os.Getenv("WEBDAV_HOST"),
-			User: os.Getenv("WEBDAV_USER"),
-			Pass: os.Getenv("WEBDAV_PASS"),
-		}
+	if n.Config.WebDAV.Host != "" {
+		webDav := n.Config.WebDAV
 		fileSystems["WEBDAV"] = webDav
 		n.WebDAV = webDav
 	}
@@ -457,40 +738,58 @@ func (n *Navitas) createFileSystems() map[string]interface{} {
 	return fileSystems
 }
 
-type RPCServer struct{}
+// RPCServer exposes maintenance mode to the navitas command line tool
+type RPCServer struct {
+	n *Navitas
+}
 
+// MaintenanceMode puts the application into, or takes it out of, maintenance mode
 func (r *RPCServer) MaintenanceMode(inMaintenanceMode bool, resp *string) error {
+	if err := r.n.SetMaintenanceMode(inMaintenanceMode); err != nil {
+		return err
+	}
+
 	if inMaintenanceMode {
-		// maintenanceMode = true
 		*resp = "Server in maintenance mode"
 	} else {
-		// maintenanceMode = false
 		*resp = "Server live!"
 	}
 	return nil
 }
 
-func (n *Navitas) listenRPC() {
+// listenRPC starts the RPC server on 127.0.0.1:RPC_PORT, if a port is configured.
+// Connections are served in the background until Shutdown closes the listener.
+func (n *Navitas) listenRPC() error {
 	// if nothing specified for rpc port, don't start
-	if os.Getenv("RPC_PORT") != "" {
-		n.InfoLog.Println("Starting RPC server on port", os.Getenv("RPC_PORT"))
-		err := rpc.Register(new(RPCServer))
-		if err != nil {
-			n.ErrorLog.Println(err)
-			return
-		}
-		listen, err := net.Listen("tcp", "127.0.0.1:"+os.Getenv("RPC_PORT"))
-		if err != nil {
-			n.ErrorLog.Println(err)
-			return
-		}
+	if n.Config.RPCPort == "" {
+		return nil
+	}
+
+	n.InfoLog.Println("Starting RPC server on port", n.Config.RPCPort)
+	server := rpc.NewServer()
+	err := server.RegisterName("RPCServer", &RPCServer{n: n})
+	if err != nil {
+		return err
+	}
+
+	listen, err := net.Listen("tcp", "127.0.0.1:"+n.Config.RPCPort)
+	if err != nil {
+		return err
+	}
+	n.rpcListener = listen
+
+	go func() {
 		for {
 			rpcConn, err := listen.Accept()
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				continue
 			}
-			go rpc.ServeConn(rpcConn)
+			go server.ServeConn(rpcConn)
 		}
+	}()
 
-	}
+	return nil
 }
@@ -0,0 +1,347 @@
+package navitas
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/bmozi/navitas/cache"
+	"github.com/bmozi/navitas/mailer"
+)
+
+func TestNewWithConfig(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal("unexpected error creating navitas from config:", err)
+	}
+
+	if n.Routes == nil || n.Session == nil || n.Render == nil {
+		t.Error("navitas was not fully initialised")
+	}
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Error("unexpected error shutting down:", err)
+	}
+}
+
+func TestNavitas_LogFileName(t *testing.T) {
+	n := &Navitas{RootPath: "/srv/app"}
+
+	n.Config.Log.File = "app.log"
+	if got := n.logFileName(); got != filepath.Join("/srv/app", "logs", "app.log") {
+		t.Error("expected a relative LOG_FILE to be in RootPath/logs, got", got)
+	}
+
+	n.Config.Log.File = "/var/log/app.log"
+	if got := n.logFileName(); got != "/var/log/app.log" {
+		t.Error("expected an absolute LOG_FILE to be used as given, got", got)
+	}
+}
+
+func TestNewWithConfig_ReturnsErrors(t *testing.T) {
+	cfg := Config{
+		Database: DatabaseConfig{
+			Type: "postgres",
+			Host: "127.0.0.1",
+			Port: "1",
+			User: "nobody",
+			Name: "nothing",
+		},
+	}
+
+	_, err := NewWithConfig(cfg, WithRootPath(t.TempDir()))
+	if err == nil {
+		t.Error("expected an error connecting to a database that does not exist")
+	}
+}
+
+// lifecycleModule runs a function when the application shuts down
+type lifecycleModule struct {
+	shutdown func(ctx context.Context) error
+}
+
+func (m *lifecycleModule) Register(n *Navitas) error          { return nil }
+func (m *lifecycleModule) Boot(ctx context.Context) error     { return nil }
+func (m *lifecycleModule) Shutdown(ctx context.Context) error { return m.shutdown(ctx) }
+
+// serveTestApp starts n on a free port, and returns the port and the result of ListenAndServe
+func serveTestApp(t *testing.T, n *Navitas) (string, <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	_ = l.Close()
+	n.Config.Port = port
+
+	served := make(chan error, 1)
+	go func() { served <- n.ListenAndServe() }()
+
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+			_ = conn.Close()
+			return port, served
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server did not start")
+	return "", nil
+}
+
+func TestNavitas_ShutdownDrainsRequestsBeforeMail(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the request queues mail after Shutdown has started, which must still be sent
+	entered := make(chan struct{})
+	finished := make(chan struct{})
+	n.Routes.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		time.Sleep(100 * time.Millisecond)
+		n.Mail.Jobs <- mailer.Message{To: "me@here.com", Template: "missing"}
+		close(finished)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	_ = l.Close()
+	n.Config.Port = port
+
+	served := make(chan error, 1)
+	go func() { served <- n.ListenAndServe() }()
+
+	go func() {
+		for {
+			resp, err := http.Get("http://127.0.0.1:" + port + "/slow")
+			if err == nil {
+				_ = resp.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	<-entered
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Fatal("unexpected error shutting down:", err)
+	}
+	if err := <-served; err != nil {
+		t.Error("expected ListenAndServe to return nil after Shutdown, got", err)
+	}
+
+	select {
+	case <-finished:
+	default:
+		t.Error("expected Shutdown to wait for the in-flight request")
+	}
+	if stats := n.Mail.Stats(); stats.Sent+stats.Failed != 1 || stats.Queued != 0 {
+		t.Errorf("expected mail queued by the request to be sent before Shutdown returned, got %+v", stats)
+	}
+}
+
+func TestNavitas_ShutdownOrder(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := openTestSQLite(t, "shutdown.db")
+	var n *Navitas
+	module := &lifecycleModule{shutdown: func(ctx context.Context) error {
+		record("module")
+		if err := pool.Ping(); err != nil {
+			t.Error("expected the database to be open while modules shut down:", err)
+		}
+		n.Mail.Jobs <- mailer.Message{To: "me@here.com", Template: "missing"}
+		return nil
+	}}
+
+	n, err = NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithDB("sqlite", pool), WithModules(module))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entered := make(chan struct{})
+	n.Routes.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		time.Sleep(100 * time.Millisecond)
+		record("request")
+	})
+
+	port, served := serveTestApp(t, n)
+
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Fatal("unexpected error shutting down:", err)
+	}
+	if err := <-served; err != nil {
+		t.Error("expected ListenAndServe to return nil after Shutdown, got", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0] != "request" || events[1] != "module" {
+		t.Errorf("expected the request to finish before modules shut down, got %v", events)
+	}
+
+	if stats := n.Mail.Stats(); stats.Sent+stats.Failed != 1 || stats.Queued != 0 {
+		t.Errorf("expected mail queued by a module to be sent before Shutdown returned, got %+v", stats)
+	}
+	if err := pool.Ping(); err == nil {
+		t.Error("expected the database to be closed")
+	}
+}
+
+func TestNavitas_ShutdownDeadline(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := openTestSQLite(t, "deadline.db")
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()), WithDB("sqlite", pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	n.Routes.Get("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	port, _ := serveTestApp(t, n)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/stuck")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = n.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context deadline to be reported, got %v", err)
+	}
+	if err := pool.Ping(); err == nil {
+		t.Error("expected the database to be closed even though the deadline passed")
+	}
+}
+
+func TestNavitas_ShutdownTimeout(t *testing.T) {
+	n, err := NewWithConfig(Config{}, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	// a hand built Config has no timeout, which must not cancel the drain straight away
+	if n.shutdownTimeout() != 30*time.Second {
+		t.Error("expected an unset shutdown timeout to default to 30s, got", n.shutdownTimeout())
+	}
+
+	n.Config.ShutdownTimeout = 5 * time.Second
+	if n.shutdownTimeout() != 5*time.Second {
+		t.Error("expected the configured shutdown timeout, got", n.shutdownTimeout())
+	}
+}
+
+func TestNavitas_ShutdownIsIdempotent(t *testing.T) {
+	cfg, err := loadConfig(envFrom(map[string]string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := n.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	if err := n.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected ListenAndServe after Shutdown to return http.ErrServerClosed, got %v", err)
+	}
+
+	// mail sent after shutdown is left queued rather than panicking on a closed channel
+	select {
+	case n.Mail.Jobs <- mailer.Message{To: "me@here.com"}:
+	default:
+	}
+}
+
+func TestNewWithConfig_RedisSessionsKeepMemoryCache(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	cfg, err := loadConfig(envFrom(map[string]string{
+		"CACHE":        "memory",
+		"SESSION_TYPE": "redis",
+		"REDIS_HOST":   server.Addr(),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+
+	if _, ok := n.Cache.(*cache.MemoryCache); !ok {
+		t.Errorf("expected CACHE=memory to be used alongside redis sessions, got %T", n.Cache)
+	}
+	if n.redisPool == nil {
+		t.Error("expected a redis pool for sessions")
+	}
+}
@@ -0,0 +1,125 @@
+package navitas
+
+import (
+	"database/sql"
+	"io/fs"
+	"log"
+	"log/slog"
+
+	"github.com/bmozi/navitas/cache"
+)
+
+// Option customises a Navitas instance created by NewWithConfig
+type Option func(*Navitas)
+
+// WithRootPath sets the folder that views, mail templates and other application files are read
+// from, and that tmp/badger, logs and tmp/maintenance are written to. It defaults to the current
+// directory.
+func WithRootPath(rootPath string) Option {
+	return func(n *Navitas) {
+		n.RootPath = rootPath
+	}
+}
+
+// WithLoggers replaces the default stdout info and error loggers
+func WithLoggers(infoLog, errorLog *log.Logger) Option {
+	return func(n *Navitas) {
+		n.InfoLog = infoLog
+		n.ErrorLog = errorLog
+	}
+}
+
+// WithLogger replaces the structured logger. The info and error loggers write through it,
+// unless they are also supplied with WithLoggers.
+func WithLogger(l *slog.Logger) Option {
+	return func(n *Navitas) {
+		n.Logger = l
+	}
+}
+
+// WithDB uses an already open database pool instead of connecting with Config.Database.
+// The pool is closed by Shutdown.
+func WithDB(dbType string, pool *sql.DB) Option {
+	return func(n *Navitas) {
+		n.DB = Database{
+			DatabaseType: dbType,
+			Pool:         pool,
+		}
+	}
+}
+
+// WithNamedDB uses an already open pool for the named database instead of connecting with its
+// entry in Config.Databases. The pool is closed by Shutdown.
+func WithNamedDB(name, dbType string, pool *sql.DB) Option {
+	return func(n *Navitas) {
+		if n.DBs == nil {
+			n.DBs = make(map[string]Database)
+		}
+		n.DBs[name] = Database{
+			DatabaseType: dbType,
+			Pool:         pool,
+		}
+	}
+}
+
+// WithDestructiveMigrations lets DATABASE_AUTO_MIGRATE run migrations on every database that drop
+// tables or columns, change column types or lock tables, as DATABASE_ALLOW_DESTRUCTIVE does for one
+func WithDestructiveMigrations() Option {
+	return func(n *Navitas) {
+		n.destructiveOK = true
+	}
+}
+
+// WithCache uses the supplied cache instead of creating one from Config.Cache
+func WithCache(c cache.Cache) Option {
+	return func(n *Navitas) {
+		n.Cache = c
+	}
+}
+
+// WithMigrations reads migration files from fsys instead of RootPath/migrations, so they can be
+// embedded in the binary. The files must be at the root of fsys; use fs.Sub to strip a folder.
+func WithMigrations(fsys fs.FS) Option {
+	return func(n *Navitas) {
+		n.migrationsFS = fsys
+	}
+}
+
+// WithNamedMigrations reads the named database's migration files from fsys instead of its
+// migrations folder
+func WithNamedMigrations(name string, fsys fs.FS) Option {
+	return func(n *Navitas) {
+		if n.dbMigrationsFS == nil {
+			n.dbMigrationsFS = make(map[string]fs.FS)
+		}
+		n.dbMigrationsFS[name] = fsys
+	}
+}
+
+// WithGoMigrations registers Go migrations, which run in version order alongside the sql files
+func WithGoMigrations(migrations ...GoMigration) Option {
+	return func(n *Navitas) {
+		for _, m := range migrations {
+			n.AddGoMigration(m)
+		}
+	}
+}
+
+// WithNamedGoMigrations registers Go migrations for the named database, which run in version
+// order alongside its sql files
+func WithNamedGoMigrations(name string, migrations ...GoMigration) Option {
+	return func(n *Navitas) {
+		for _, m := range migrations {
+			n.AddNamedGoMigration(name, m)
+		}
+	}
+}
+
+// WithSeeders registers seeders, which run in the order given
+func WithSeeders(seeders ...Seeder) Option {
+	return func(n *Navitas) {
+		for _, s := range seeders {
+			n.AddSeeder(s)
+		}
+	}
+}
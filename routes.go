@@ -10,12 +10,55 @@ import (
 func (n *Navitas) routes() http.Handler {
 	mux := chi.NewRouter()
 	mux.Use(middleware.RequestID)
+	mux.Use(n.RememberPeer)
 	mux.Use(middleware.RealIP)
-	if n.Debug {
-		mux.Use(middleware.Logger)
+	mux.Use(n.RequestLogger)
+	if n.Metrics != nil {
+		mux.Use(n.CollectMetrics)
 	}
 	mux.Use(middleware.Recoverer)
+	if n.Config.Secure {
+		mux.Use(n.HSTS)
+	}
+	mux.Use(n.CheckForMaintenanceMode)
 	mux.Use(n.SessionLoad)
 	mux.Use(n.NoSurf)
+
 	return mux
 }
+
+// Handler returns the handler that serves the application, and is what ListenAndServe serves.
+// Applications that run their own http.Server, or test against the application, must serve
+// Handler rather than Routes, and call it once they have added their own middleware to Routes.
+//
+// The health probes are answered here rather than on Routes, since chi refuses middleware added
+// to a mux that already has routes, and the application adds its middleware to Routes after init.
+// The metrics path is answered here for the same reason. Every other request goes to Routes.
+// Module routes are added to Routes here, by which time the application's middleware is in place.
+func (n *Navitas) Handler() http.Handler {
+	n.mountModuleRoutes()
+
+	// with METRICS_PORT set, metrics are served by ListenAndServe on their own port instead
+	var metricsHandler http.Handler
+	if n.Metrics != nil && n.Config.Metrics.Port == "" {
+		metricsHandler = n.metricsHandler()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && metricsHandler != nil && r.URL.Path == n.Config.Metrics.Path {
+			metricsHandler.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && n.Config.Health.Enabled {
+			switch r.URL.Path {
+			case "/healthz":
+				n.Liveness(w, r)
+				return
+			case "/readyz":
+				n.Readiness(w, r)
+				return
+			}
+		}
+		n.Routes.ServeHTTP(w, r)
+	})
+}
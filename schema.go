@@ -0,0 +1,218 @@
+package navitas
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// schemaVersionHeader starts the first line of a schema dump, and records the migration version
+// the schema is at
+const schemaVersionHeader = "-- navitas schema version: "
+
+// migrationsTable is where the migration runner records the current version. It is left out of
+// schema dumps, and rewritten when a dump is loaded.
+const migrationsTable = "schema_migrations"
+
+// DumpSchema writes the database's tables, indexes and constraints to w as sql, headed by the
+// current migration version. Postgres and mysql are dumped with pg_dump and mysqldump, which must
+// be installed; sqlite is read from sqlite_master.
+func (n *Navitas) DumpSchema(ctx context.Context, w io.Writer) error {
+	version, err := n.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	var schema bytes.Buffer
+	db := n.databaseConfig()
+
+	switch db.Type {
+	case "postgres", "postgresql":
+		err = runSchemaTool(ctx, "pg_dump", postgresToolEnv(db), nil, &schema,
+			"--schema-only", "--no-owner", "--no-privileges",
+			"--exclude-table="+migrationsTable,
+			"-h", db.Host, "-p", portOr(db.Port, "5432"), "-U", db.User, db.Name)
+
+	case "mysql", "mariadb":
+		err = runSchemaTool(ctx, "mysqldump", mysqlToolEnv(db), nil, &schema,
+			"--no-data", "--skip-comments", "--skip-add-drop-table", "--routines", "--triggers",
+			"--ignore-table="+db.Name+"."+migrationsTable,
+			"-h", db.Host, "-P", portOr(db.Port, "3306"), "-u", db.User, db.Name)
+
+	case "sqlite":
+		err = n.dumpSQLiteSchema(ctx, &schema)
+
+	default:
+		err = fmt.Errorf("schema dumps are not supported for database type %q", db.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s%d\n\n", schemaVersionHeader, version); err != nil {
+		return err
+	}
+	_, err = schema.WriteTo(w)
+	return err
+}
+
+// LoadSchema creates the schema written by DumpSchema in an empty database, and records its
+// migration version so that only newer migrations run afterwards
+func (n *Navitas) LoadSchema(ctx context.Context, r io.Reader) error {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, schemaVersionHeader) {
+		return errors.New("not a navitas schema dump: missing version header")
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, schemaVersionHeader)))
+	if err != nil {
+		return fmt.Errorf("invalid schema version: %w", err)
+	}
+
+	current, err := n.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current != database.NilVersion {
+		return fmt.Errorf("database is already at migration %d; schemas can only be loaded into an empty database", current)
+	}
+
+	db := n.databaseConfig()
+	switch db.Type {
+	case "postgres", "postgresql":
+		err = runSchemaTool(ctx, "psql", postgresToolEnv(db), br, io.Discard,
+			"--quiet", "--no-psqlrc", "-v", "ON_ERROR_STOP=1",
+			"-h", db.Host, "-p", portOr(db.Port, "5432"), "-U", db.User, "-d", db.Name)
+
+	case "mysql", "mariadb":
+		err = runSchemaTool(ctx, "mysql", mysqlToolEnv(db), br, io.Discard,
+			"-h", db.Host, "-P", portOr(db.Port, "3306"), "-u", db.User, db.Name)
+
+	case "sqlite":
+		err = n.loadSQLiteSchema(ctx, br)
+
+	default:
+		err = fmt.Errorf("schema loads are not supported for database type %q", db.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	drv, err := database.Open(n.BuildMigrationURL())
+	if err != nil {
+		return err
+	}
+	defer drv.Close()
+
+	return drv.SetVersion(version, false)
+}
+
+// schemaVersion returns the current migration version, or database.NilVersion when no migration
+// has run. A dirty database is refused, since its schema is only partly migrated.
+func (n *Navitas) schemaVersion() (int, error) {
+	drv, err := database.Open(n.BuildMigrationURL())
+	if err != nil {
+		return 0, err
+	}
+	defer drv.Close()
+
+	version, dirty, err := drv.Version()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("migration %d is dirty; fix it and run migrate force first", version)
+	}
+	return version, nil
+}
+
+func (n *Navitas) dumpSQLiteSchema(ctx context.Context, w io.Writer) error {
+	db, err := sql.Open("sqlite", n.BuildDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// tables come first, so the indexes and triggers that refer to them can be created on load
+	rows, err := db.QueryContext(ctx, `select sql from sqlite_master
+		where sql is not null and name not like 'sqlite_%' and tbl_name <> ?
+		order by case type when 'table' then 0 when 'view' then 1 else 2 end, rowid`, migrationsTable)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s;\n\n", stmt); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (n *Navitas) loadSQLiteSchema(ctx context.Context, r io.Reader) error {
+	schema, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite", n.BuildDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, string(schema))
+	return err
+}
+
+// runSchemaTool runs one of the database's command line tools, reporting its stderr on failure
+func runSchemaTool(ctx context.Context, name string, env []string, stdin io.Reader, stdout io.Writer, args ...string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s was not found; install the database client tools: %w", name, err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// postgresToolEnv passes the password and ssl mode to pg_dump and psql without putting them on
+// the command line
+func postgresToolEnv(db DatabaseConfig) []string {
+	return []string{"PGPASSWORD=" + db.Password, "PGSSLMODE=" + db.SSLMode}
+}
+
+func mysqlToolEnv(db DatabaseConfig) []string {
+	return []string{"MYSQL_PWD=" + db.Password}
+}
+
+func portOr(port, def string) string {
+	if port == "" {
+		return def
+	}
+	return port
+}
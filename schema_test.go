@@ -0,0 +1,70 @@
+package navitas
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNavitas_DumpAndLoadSchema(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	err := os.MkdirAll(filepath.Join(root, "migrations"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"1_create_users.up.sql":   "create table users (id integer primary key, email text not null);",
+		"1_create_users.down.sql": "drop table users;",
+		"2_index_users.up.sql":    "create unique index users_email_idx on users (email);",
+		"2_index_users.down.sql":  "drop index users_email_idx;",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, "migrations", name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := &Navitas{RootPath: root, Config: Config{Database: DatabaseConfig{Type: "sqlite", Name: "source.db"}}}
+	if err := source.MigrateUp(source.BuildMigrationURL()); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump bytes.Buffer
+	if err := source.DumpSchema(ctx, &dump); err != nil {
+		t.Fatal("unexpected error dumping schema:", err)
+	}
+	if !strings.HasPrefix(dump.String(), schemaVersionHeader+"2\n") || strings.Contains(dump.String(), migrationsTable) {
+		t.Errorf("unexpected schema dump:\n%s", dump.String())
+	}
+
+	target := &Navitas{RootPath: root, Config: Config{Database: DatabaseConfig{Type: "sqlite", Name: "target.db"}}}
+	if err := target.LoadSchema(ctx, bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatal("unexpected error loading schema:", err)
+	}
+
+	status, err := target.MigrationStatus(target.BuildMigrationURL())
+	if err != nil || status.Version != 2 || len(status.Pending()) != 0 {
+		t.Errorf("expected loaded database to be at version 2, got %+v: %v", status, err)
+	}
+
+	db, err := sql.Open("sqlite", target.BuildDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var indexes int
+	err = db.QueryRow("select count(*) from sqlite_master where type = 'index' and name = 'users_email_idx'").Scan(&indexes)
+	if err != nil || indexes != 1 {
+		t.Error("expected the index to be loaded:", err)
+	}
+
+	if err := target.LoadSchema(ctx, bytes.NewReader(dump.Bytes())); err == nil {
+		t.Error("expected loading into a migrated database to be refused")
+	}
+}
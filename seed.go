@@ -0,0 +1,87 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// Seeder loads development or demo data into the database. Seeders run in ascending Order, so a
+// seeder can rely on the data of those with a lower Order. `navitas make seeder` sets Order to the
+// time the seeder was created, like a migration version.
+type Seeder struct {
+	Name  string
+	Order int64
+	Run   func(tx *sql.Tx) error
+}
+
+// AddSeeder registers a seeder. Seeders with the same Order run in the order they were added.
+// Adding a seeder with an existing name replaces it.
+func (n *Navitas) AddSeeder(s Seeder) {
+	for i, existing := range n.seeders {
+		if existing.Name == s.Name {
+			n.seeders[i] = s
+			return
+		}
+	}
+	n.seeders = append(n.seeders, s)
+}
+
+// Seed runs every registered seeder, or only the named ones, in order inside a single
+// transaction, so a failing seeder leaves the database untouched
+func (n *Navitas) Seed(ctx context.Context, names ...string) error {
+	seeders := append([]Seeder(nil), n.seeders...)
+	if len(names) > 0 {
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+
+		seeders = nil
+		for _, s := range n.seeders {
+			if wanted[s.Name] {
+				seeders = append(seeders, s)
+				delete(wanted, s.Name)
+			}
+		}
+		for name := range wanted {
+			return fmt.Errorf("no seeder named %q", name)
+		}
+	}
+
+	if len(seeders) == 0 {
+		return errors.New("no seeders registered")
+	}
+	sort.SliceStable(seeders, func(i, j int) bool { return seeders[i].Order < seeders[j].Order })
+
+	return n.DB.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		for _, s := range seeders {
+			n.Logger.Info("seeding", "seeder", s.Name)
+			if err := s.Run(tx); err != nil {
+				return fmt.Errorf("seeder %s: %w", s.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ResetDatabase runs every down migration and then every up migration, leaving an empty database
+// at the latest schema
+func (n *Navitas) ResetDatabase() error {
+	dsn := n.BuildMigrationURL()
+
+	err := n.MigrateDownAll(dsn)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	err = n.MigrateUp(dsn)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNavitas_Seed(t *testing.T) {
+	n := &Navitas{
+		DB:     Database{DatabaseType: "sqlite", Pool: openTestSQLite(t, "seed.db")},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := context.Background()
+
+	_, err := n.DB.Pool.Exec("create table items (name text not null)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(name string) func(tx *sql.Tx) error {
+		return func(tx *sql.Tx) error {
+			_, err := tx.Exec("insert into items (name) values (?)", name)
+			return err
+		}
+	}
+	n.AddSeeder(Seeder{Name: "first", Run: insert("first")})
+	n.AddSeeder(Seeder{Name: "second", Run: insert("second")})
+
+	count := func() int {
+		var c int
+		_ = n.DB.Pool.QueryRow("select count(*) from items").Scan(&c)
+		return c
+	}
+
+	if err := n.Seed(ctx); err != nil || count() != 2 {
+		t.Fatalf("expected every seeder to run, got %d rows: %v", count(), err)
+	}
+
+	if err := n.Seed(ctx, "second"); err != nil || count() != 3 {
+		t.Errorf("expected only the named seeder to run, got %d rows: %v", count(), err)
+	}
+
+	if err := n.Seed(ctx, "missing"); err == nil {
+		t.Error("expected an error for an unknown seeder")
+	}
+
+	n.AddSeeder(Seeder{Name: "broken", Run: func(tx *sql.Tx) error { return errors.New("boom") }})
+	if err := n.Seed(ctx); err == nil || count() != 3 {
+		t.Errorf("expected a failing seeder to roll back every seeder, got %d rows: %v", count(), err)
+	}
+}
+
+func TestNavitas_SeedOrder(t *testing.T) {
+	n := &Navitas{
+		DB:     Database{DatabaseType: "sqlite", Pool: openTestSQLite(t, "order.db")},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	var ran []string
+	seeder := func(name string, order int64) Seeder {
+		return Seeder{Name: name, Order: order, Run: func(tx *sql.Tx) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+
+	// added in file name order, which is not the order they depend on each other
+	n.AddSeeder(seeder("articles", 300))
+	n.AddSeeder(seeder("roles", 100))
+	n.AddSeeder(seeder("users", 200))
+	n.AddSeeder(seeder("tags", 200))
+
+	if err := n.Seed(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"roles", "users", "tags", "articles"}
+	if strings.Join(ran, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected seeders to run in order %v, got %v", expected, ran)
+	}
+
+	ran = nil
+	if err := n.Seed(context.Background(), "articles", "roles"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ran, ",") != "roles,articles" {
+		t.Errorf("expected named seeders to run in order too, got %v", ran)
+	}
+}
@@ -0,0 +1,49 @@
+package navitas
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"path/filepath"
+)
+
+// loadTLSConfig loads the configured certificate and key into a tls.Config for the web server
+func (n *Navitas) loadTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(n.resolvePath(n.Config.TLS.CertFile), n.resolvePath(n.Config.TLS.KeyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// redirectToHTTPS returns a handler that permanently redirects every request to the HTTPS port on
+// SERVER_NAME, which LoadConfig requires along with TLS_REDIRECT_PORT. The request's Host header
+// is never used, since anyone can send any Host.
+func (n *Navitas) redirectToHTTPS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := n.Config.ServerName
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		target := "https://" + host
+		if n.Config.Port != "443" {
+			target += ":" + n.Config.Port
+		}
+		target += r.URL.RequestURI()
+
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// resolvePath returns p unchanged if it is absolute, and relative to RootPath otherwise
+func (n *Navitas) resolvePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(n.RootPath, p)
+}
@@ -0,0 +1,154 @@
+package navitas
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self signed certificate and its key to dir as name.pem and name-key.pem
+func writeTestCert(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNavitas_LoadTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeTestCert(t, dir, "cert")
+	_, otherKey := writeTestCert(t, dir, "other")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{"valid pair", "cert.pem", "cert-key.pem", false},
+		{"absolute paths", cert, key, false},
+		{"missing cert", "missing.pem", "cert-key.pem", true},
+		{"missing key", "cert.pem", "missing-key.pem", true},
+		{"mismatched pair", "cert.pem", otherKey, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Navitas{RootPath: dir}
+			n.Config.TLS = TLSConfig{CertFile: tt.certFile, KeyFile: tt.keyFile}
+
+			cfg, err := n.loadTLSConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error loading the certificate")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Certificates) != 1 {
+				t.Error("expected the certificate to be loaded")
+			}
+		})
+	}
+}
+
+func TestNavitas_RedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverName string
+		port       string
+		host       string
+		want       string
+	}{
+		{"server name on 443", "example.com", "443", "example.com", "https://example.com/account?tab=1"},
+		{"server name on another port", "example.com", "8443", "example.com:8080", "https://example.com:8443/account?tab=1"},
+		{"ignores the host header", "example.com", "443", "evil.com", "https://example.com/account?tab=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Navitas{}
+			n.Config.ServerName, n.Config.Port = tt.serverName, tt.port
+
+			r := httptest.NewRequest("GET", "/account?tab=1", nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			n.redirectToHTTPS().ServeHTTP(w, r)
+
+			if w.Code != http.StatusPermanentRedirect {
+				t.Errorf("expected status %d and got %d", http.StatusPermanentRedirect, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("expected redirect to %s and got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNavitas_HSTSOnlyWhenSecure(t *testing.T) {
+	for _, secure := range []string{"true", "false"} {
+		t.Run("SECURE="+secure, func(t *testing.T) {
+			cfg, err := loadConfig(envFrom(map[string]string{"SECURE": secure, "HSTS_MAX_AGE": "600"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := NewWithConfig(*cfg, WithRootPath(t.TempDir()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer n.Shutdown(context.Background())
+
+			n.Routes.Get("/page", func(w http.ResponseWriter, r *http.Request) {})
+			w := httptest.NewRecorder()
+			n.Routes.ServeHTTP(w, httptest.NewRequest("GET", "/page", nil))
+
+			got := w.Header().Get("Strict-Transport-Security")
+			if secure == "true" && got != "max-age=600" {
+				t.Errorf("expected HSTS header max-age=600 and got %q", got)
+			}
+			if secure == "false" && got != "" {
+				t.Errorf("expected no HSTS header and got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxTxAttempts is how many times WithTx runs a transaction that keeps failing with a retryable error
+const maxTxAttempts = 5
+
+// txBackoff is the delay before the first retry. It doubles on every attempt, with jitter.
+const txBackoff = 10 * time.Millisecond
+
+type txContextKey struct{}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx, so models can run the same queries inside
+// and outside of a transaction
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// ContextWithTx returns a copy of ctx carrying tx. Models that use Database.Querier, and any
+// WithTx called with the returned context, join the transaction instead of starting their own.
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if there is one
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+
+// Querier returns the transaction carried by ctx, or the primary pool when there is none
+func (d Database) Querier(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return d.Pool
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling back if it returns an
+// error or panics. Serialization failures and deadlocks are retried with backoff, so fn must be
+// safe to run more than once. If ctx already carries a transaction, fn joins it and the outermost
+// WithTx decides whether to commit.
+func (d Database) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return fn(tx)
+	}
+
+	if d.Pool == nil {
+		return errors.New("navitas: no database connection")
+	}
+
+	var err error
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := txBackoff << (attempt - 1)
+			backoff += time.Duration(rand.Int63n(int64(backoff)))
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		err = d.runTx(ctx, opts, fn)
+		if err == nil || !retryableTxError(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("transaction failed after %d attempts: %w", maxTxAttempts, err)
+}
+
+// runTx runs one attempt of a transaction
+func (d Database) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	return runTxOn(ctx, d.Pool, opts, fn)
+}
+
+// txBeginner is satisfied by both *sql.DB and *sql.Conn
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// runTxOn runs fn in a transaction on db, committing it if fn returns nil
+func runTxOn(ctx context.Context, db txBeginner, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			_ = tx.Rollback()
+			err = fmt.Errorf("transaction panicked: %v", rec)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retryableTxError reports whether err means the transaction lost a conflict with another one,
+// and would probably succeed if run again
+func retryableTxError(err error) bool {
+	// postgres: serialization_failure and deadlock_detected. Both pgx and lib/pq errors report
+	// their code through SQLState.
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		switch pgErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+
+	// mysql and mariadb: deadlock found, and lock wait timeout exceeded
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1213, 1205:
+			return true
+		}
+	}
+
+	return false
+}
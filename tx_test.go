@@ -0,0 +1,107 @@
+package navitas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestDatabase_WithTx(t *testing.T) {
+	db := Database{Pool: openTestSQLite(t, "tx.db")}
+	ctx := context.Background()
+
+	_, err := db.Pool.Exec("create table items (name text not null)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		var n int
+		if err := db.Pool.QueryRow("select count(*) from items").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	err = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec("insert into items (name) values ('committed')")
+		return err
+	})
+	if err != nil || count() != 1 {
+		t.Fatal("expected transaction to commit:", err)
+	}
+
+	errBoom := errors.New("boom")
+	err = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		_, _ = tx.Exec("insert into items (name) values ('rolled back')")
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) || count() != 1 {
+		t.Error("expected transaction to roll back and return the error:", err)
+	}
+
+	err = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		_, _ = tx.Exec("insert into items (name) values ('panicked')")
+		panic("oops")
+	})
+	if err == nil || count() != 1 {
+		t.Error("expected panic to roll back and be returned as an error:", err)
+	}
+
+	attempts := 0
+	err = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		attempts++
+		if attempts < 3 {
+			return sqlStateError("40001")
+		}
+		_, err := tx.Exec("insert into items (name) values ('retried')")
+		return err
+	})
+	if err != nil || attempts != 3 || count() != 2 {
+		t.Errorf("expected serialization failures to be retried, got %d attempts: %v", attempts, err)
+	}
+
+	attempts = 0
+	_ = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		attempts++
+		return sqlStateError("23505")
+	})
+	if attempts != 1 {
+		t.Error("expected other errors not to be retried, got attempts:", attempts)
+	}
+}
+
+func TestDatabase_WithTx_JoinsContext(t *testing.T) {
+	db := Database{Pool: openTestSQLite(t, "join.db")}
+
+	outer, err := db.Pool.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outer.Rollback()
+
+	ctx := ContextWithTx(context.Background(), outer)
+
+	if db.Querier(ctx) != outer {
+		t.Error("expected Querier to return the transaction in the context")
+	}
+	if db.Querier(context.Background()) != db.Pool {
+		t.Error("expected Querier to return the pool without a transaction")
+	}
+
+	err = db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+		if tx != outer {
+			t.Error("expected WithTx to join the transaction in the context")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,12 @@
 package navitas
 
 import (
-	"database/sql"
+	"time"
+
+	"github.com/bmozi/navitas/filesystems/miniofilesystem"
+	"github.com/bmozi/navitas/filesystems/s3filesystem"
+	"github.com/bmozi/navitas/filesystems/sftpfilesystem"
+	"github.com/bmozi/navitas/filesystems/webdavfilesystem"
 )
 
 type initPaths struct {
@@ -9,26 +14,158 @@ type initPaths struct {
 	folderNames []string
 }
 
-type cookieConfig struct {
-	name     string
-	lifetime string
-	persist  string
-	secure   string
-	domain   string
+// Config is the typed application configuration. It is normally built once at boot by
+// LoadConfig, which applies defaults and validates every value it reads.
+type Config struct {
+	AppName         string
+	AppURL          string
+	Environment     string
+	Debug           bool
+	Port            string
+	ServerName      string
+	Secure          bool
+	Renderer        string
+	EncryptionKey   string
+	SessionType     string
+	Cache           string
+	RPCPort         string
+	ShutdownTimeout time.Duration // 30 seconds when zero
+	Cookie          CookieConfig
+	Database        DatabaseConfig
+	Databases       map[string]DatabaseConfig // named connections, opened alongside Database
+	Redis           RedisConfig
+	MemoryCache     MemoryCacheConfig
+	Uploads         UploadConfig
+	Maintenance     MaintenanceConfig
+	Log             LogConfig
+	Health          HealthConfig
+	Metrics         MetricsConfig
+	TLS             TLSConfig
+	Mail            MailConfig
+	S3              s3filesystem.S3
+	Minio           miniofilesystem.Minio
+	SFTP            sftpfilesystem.SFTP
+	WebDAV          webdavfilesystem.WebDAV
+}
+
+// CookieConfig holds the session cookie settings
+type CookieConfig struct {
+	Name     string
+	Lifetime int // minutes
+	Persist  bool
+	Secure   bool
+	Domain   string
+}
+
+// DatabaseConfig holds the settings used to build the database DSN. SSLMode takes the postgres
+// sslmode values; for mysql and mariadb they are mapped onto the driver's tls options.
+type DatabaseConfig struct {
+	Type     string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+	Charset  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// Replicas are read replicas, as host or host:port, reached with the same credentials
+	Replicas             []string
+	ReplicaCheckInterval time.Duration
+
+	// AutoMigrate runs pending migrations when the application boots
+	AutoMigrate bool
+
+	// AllowDestructive lets AutoMigrate run migrations that drop tables or columns, change column
+	// types or lock tables, which are otherwise refused
+	AllowDestructive bool
+
+	// Migrations is the folder of migration files, relative to the application root
+	Migrations string
+}
+
+// RedisConfig holds the settings for the redis pool used by the cache and sessions
+type RedisConfig struct {
+	Host     string
+	Password string
+	Prefix   string
+}
+
+// MemoryCacheConfig limits the in-memory cache. The least recently used entries are evicted once
+// either limit is reached; 0 means no limit.
+type MemoryCacheConfig struct {
+	MaxEntries      int
+	MaxBytes        int64
+	CleanupInterval time.Duration // how often expired entries are removed
+}
+
+// UploadConfig limits what may be uploaded through UploadFile
+type UploadConfig struct {
+	AllowedMimeTypes []string
+	MaxUploadSize    int64
+}
+
+// MaintenanceConfig controls the response served while the application is down for maintenance,
+// and who may bypass it
+type MaintenanceConfig struct {
+	RetryAfter     int // seconds
+	AllowedIPs     []string
+	TrustedProxies []string // proxies whose X-Forwarded-For header is believed
+	Secret         string   // requires EncryptionKey, which keys the bypass cookie
+}
+
+// TLSConfig holds the certificate used to serve HTTPS, and the HSTS policy sent when Secure is true.
+// Relative file paths are resolved against the application root path.
+type TLSConfig struct {
+	CertFile              string
+	KeyFile               string
+	RedirectPort          string
+	HSTSMaxAge            int // seconds
+	HSTSIncludeSubdomains bool
+}
+
+// LogConfig controls the structured logger. When File is set, logs are written to that file in
+// RootPath/logs, or at File itself if it is absolute, instead of stdout, and rotated once they
+// reach MaxSize or are MaxAge old.
+type LogConfig struct {
+	Level   string
+	Format  string
+	File    string
+	MaxSize int // megabytes
+	MaxAge  int // days
 }
 
-type databaseConfig struct {
-	dsn      string
-	database string
+// HealthConfig controls the /healthz and /readyz endpoints, which are answered by Handler
+type HealthConfig struct {
+	Enabled bool
+	Timeout time.Duration // per check
 }
 
-type Database struct {
-	DatabaseType string
-	Pool         *sql.DB
+// MetricsConfig controls the Prometheus metrics endpoint, which is answered by Handler. When Port
+// is set metrics are served on their own listener by ListenAndServe instead, and when Token is set
+// scrapes must send it as a bearer token.
+type MetricsConfig struct {
+	Enabled bool
+	Path    string
+	Port    string
+	Token   string
 }
 
-type redisConfig struct {
-	host     string
-	password string
-	prefix   string
+// MailConfig holds the SMTP and mail api settings
+type MailConfig struct {
+	Domain      string
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	Encryption  string
+	FromName    string
+	FromAddress string
+	API         string
+	APIKey      string
+	APIURL      string
 }
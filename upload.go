@@ -41,7 +41,7 @@ func (n *Navitas) UploadFile(r *http.Request, destination, field string, fs file
 }
 
 func (n *Navitas) getFileToUpload(r *http.Request, fieldName string) (string, error) {
-	_ = r.ParseMultipartForm(n.config.uploads.maxUploadSize)
+	_ = r.ParseMultipartForm(n.Config.Uploads.MaxUploadSize)
 
 	file, header, err := r.FormFile(fieldName)
 	if err != nil {
@@ -60,7 +60,7 @@ func (n *Navitas) getFileToUpload(r *http.Request, fieldName string) (string, er
 		return "", err
 	}
 
-	if !inSlice(n.config.uploads.allowedMimeTypes, mimeType.String()) {
+	if !inSlice(n.Config.Uploads.AllowedMimeTypes, mimeType.String()) {
 		return "", errors.New("invalid file type uploaded")
 	}
 